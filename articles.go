@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const createdAtLayout = "2006-01-02 15:04:05"
+
+type Article struct {
+	ID          int64    `json:"id"`
+	Title       string   `json:"title"`
+	Excerpt     string   `json:"excerpt"`
+	LongContent string   `json:"long_content"`
+	Sources     []string `json:"sources"`
+	Links       []string `json:"links"`
+	Category    string   `json:"category"`
+	Subcategory string   `json:"subcategory,omitempty"`
+	AiModel     string   `json:"ai_model"`
+	// PromptVersion is the version of the summarizer prompt that wrote
+	// the story.
+	PromptVersion string        `json:"prompt_version,omitempty"`
+	Image         *ArticleImage `json:"image,omitempty"`
+	// QualityFlags are the checks the story still failed after the
+	// summarizer retries.
+	QualityFlags []QualityIssue `json:"quality_flags,omitempty"`
+	Language     string         `json:"language"`
+	CreatedAt    time.Time      `json:"created_at"`
+}
+
+type ArticleQuery struct {
+	Category string
+	Since    time.Time
+	Limit    int
+	// Language selects an edition; articles without a translation to it
+	// are left out. Empty means the Indonesian original.
+	Language string
+}
+
+func splitList(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+func InsertArticle(ctx context.Context, db *sql.DB, article *Article) (err error) {
+	ctx, span := startSpan(ctx, "store.insert_article", attribute.Int64("article.id", article.ID))
+	defer func() {
+		if err != nil {
+			err = &StoreError{Operation: fmt.Sprintf("inserting article %d", article.ID), Err: err}
+		}
+		endSpan(span, err)
+	}()
+
+	qualityFlags := article.QualityFlags
+	if qualityFlags == nil {
+		qualityFlags = []QualityIssue{}
+	}
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO articles (id, title, excerpt, long_content, sources, links, category, subcategory, ai_model, prompt_version, image, quality_flags, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, article.ID, article.Title, article.Excerpt, article.LongContent, strings.Join(article.Sources, ","), strings.Join(article.Links, ","), article.Category, article.Subcategory, article.AiModel,
+		article.PromptVersion, lo.Ternary(article.Image != nil, marshalColumn(article.Image), ""), marshalColumn(qualityFlags), article.CreatedAt.Format(createdAtLayout))
+	return err
+}
+
+func ListArticles(db *sql.DB, query ArticleQuery) ([]Article, error) {
+	language := lo.CoalesceOrEmpty(query.Language, LanguageIndonesian)
+	stmt := `SELECT a.id, a.title, a.excerpt, a.long_content, a.sources, a.links, a.category, a.subcategory, a.ai_model, a.prompt_version, a.image, a.quality_flags, a.created_at FROM articles a WHERE 1 = 1`
+	var args []any
+	if language != LanguageIndonesian {
+		stmt = `SELECT a.id, t.title, t.excerpt, t.long_content, a.sources, a.links, a.category, a.subcategory, t.ai_model, a.prompt_version, a.image, a.quality_flags, a.created_at
+			FROM articles a JOIN article_translations t ON t.article_id = a.id AND t.language = ? WHERE 1 = 1`
+		args = append(args, language)
+	}
+	if query.Category != "" {
+		stmt += ` AND a.category = ?`
+		args = append(args, query.Category)
+	}
+	if !query.Since.IsZero() {
+		stmt += ` AND a.created_at >= ?`
+		args = append(args, query.Since.Format(createdAtLayout))
+	}
+	stmt += ` ORDER BY a.created_at DESC, a.id DESC`
+	if query.Limit > 0 {
+		stmt += ` LIMIT ?`
+		args = append(args, query.Limit)
+	}
+
+	rows, err := db.Query(stmt, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying articles: %v", err)
+	}
+	defer rows.Close()
+
+	var articles []Article
+	for rows.Next() {
+		var article Article
+		var sources, links, image, qualityFlags, createdAt string
+		err := rows.Scan(&article.ID, &article.Title, &article.Excerpt, &article.LongContent, &sources, &links, &article.Category, &article.Subcategory, &article.AiModel, &article.PromptVersion, &image, &qualityFlags, &createdAt)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning article: %v", err)
+		}
+		article.Language = language
+		article.Sources = splitList(sources)
+		article.Links = splitList(links)
+		if image != "" {
+			json.Unmarshal([]byte(image), &article.Image)
+		}
+		json.Unmarshal([]byte(qualityFlags), &article.QualityFlags)
+		article.CreatedAt, err = time.ParseInLocation(createdAtLayout, createdAt, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing created_at of article %d: %v", article.ID, err)
+		}
+		articles = append(articles, article)
+	}
+
+	return articles, rows.Err()
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// globalCleaningRules is the key of the rules applied to every publisher.
+const globalCleaningRules = "*"
+
+// defaultCleaningRules hold the common Indonesian news boilerplate and the
+// publisher specific inserts the extractors used to skip by hand.
+var defaultCleaningRules = map[string]CleaningRules{
+	globalCleaningRules: {
+		Selectors: []string{"script", "style", "iframe", "figcaption", ".ads", ".advertisement", "[class*=baca-juga]", "[class*=newsletter]"},
+		Paragraphs: []string{
+			`(?i)^baca(\s+juga)?\s*:`,
+			`(?i)^baca juga\b`,
+			`(?i)^(simak|tonton|lihat|saksikan)\s+(juga\s+)?(video|berita|selengkapnya|live streaming)\b`,
+			`(?i)^(dapatkan|ikuti|gabung|join)\b.*\b(whatsapp|telegram|google news|newsletter|saluran|channel)\b`,
+			`(?i)^download (aplikasi|app)\b`,
+			`(?i)^(pilihan editor|artikel terkait|berita terkait)\b`,
+			`(?i)^advertisement$`,
+			`(?i)^scroll to (continue|resume)`,
+			`(?i)^\(?(penulis|editor|reporter|pewarta|penyunting)\s*:`,
+			`(?i)^\*{0,2}\s*(catatan redaksi|disclaimer)\b`,
+		},
+		Strip: []string{
+			// upper case only, the word itself is kept in English articles
+			`\bADVERTISEMENT\b`,
+			`(?i)SCROLL TO (CONTINUE|RESUME) WITH CONTENT`,
+			`\[Gambas:[^\]]*\]`,
+			// reporter initials closing CNN and CNBC articles, e.g. "(fby/tsa)",
+			// possibly after a wire agency as in "(Reuters/dna)"
+			`\s\((?:[A-Za-z]{2,10}/)+[A-Za-z]{2,5}\)\s*$`,
+		},
+	},
+	"CNBC": {
+		Selectors: []string{".linksisip"},
+	},
+	"CNN": {
+		Selectors: []string{".para_caption", ".linksisip"},
+	},
+	"Liputan6": {
+		Selectors: []string{".baca-juga-collections", ".article-ad", ".promo-banner"},
+	},
+	"Kumparan": {
+		Paragraphs: []string{`(?i)^kumparan\w*\s+adalah\b`},
+	},
+	"Detik": {
+		Selectors: []string{".parallaxindetail", ".detail__body-tag", ".sisip_embed_sosmed", "table.linksisip"},
+	},
+	"Tempo": {
+		Paragraphs: []string{`(?i)^pilihan editor\s*:`},
+	},
+	"Antara": {
+		Selectors: []string{".text-muted", ".quote_old"},
+		Strip:     []string{`(?i)\bPewarta\s*:.*$`},
+	},
+	"Tribun": {
+		Selectors:  []string{".baca", ".ads-placeholder"},
+		Paragraphs: []string{`(?i)^artikel ini telah (tayang|terbit) di\b`},
+	},
+}
+
+// paragraphSelector picks the elements matched against paragraph rules.
+const paragraphSelector = "p, li, h2, h3, h4, blockquote, span[data-qa-id=story-paragraph]"
+
+type stripRule struct {
+	source string
+	re     *regexp.Regexp
+}
+
+// Cleaner strips boilerplate from article pages and their extracted text.
+type Cleaner struct {
+	selectors  []string
+	paragraphs []stripRule
+	strip      []stripRule
+	// Stripped counts the fragments removed by each rule.
+	Stripped map[string]int
+}
+
+var (
+	cleanersMu sync.Mutex
+	cleaners   = map[string]*Cleaner{}
+)
+
+// NewCleaner returns a cleaner with the built-in and configured rules for
+// publisher. Compiled rules are cached; every call has its own counts.
+func NewCleaner(publisher string) *Cleaner {
+	cleanersMu.Lock()
+	defer cleanersMu.Unlock()
+	compiled, ok := cleaners[publisher]
+	if !ok {
+		compiled = compileCleaner(publisher)
+		cleaners[publisher] = compiled
+	}
+	return &Cleaner{
+		selectors:  compiled.selectors,
+		paragraphs: compiled.paragraphs,
+		strip:      compiled.strip,
+		Stripped:   map[string]int{},
+	}
+}
+
+func compileCleaner(publisher string) *Cleaner {
+	cleaner := &Cleaner{}
+	rules := []CleaningRules{
+		defaultCleaningRules[globalCleaningRules],
+		defaultCleaningRules[publisher],
+		config.Cleaning[globalCleaningRules],
+		config.Cleaning[publisher],
+	}
+	compile := func(patterns []string) []stripRule {
+		var compiled []stripRule
+		for _, pattern := range patterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				logger.Warn("Invalid cleaning rule, ignoring", "publisher", publisher, "pattern", pattern, "error", err)
+				continue
+			}
+			compiled = append(compiled, stripRule{source: pattern, re: re})
+		}
+		return compiled
+	}
+	for _, rule := range rules {
+		cleaner.selectors = append(cleaner.selectors, rule.Selectors...)
+		cleaner.paragraphs = append(cleaner.paragraphs, compile(rule.Paragraphs)...)
+		cleaner.strip = append(cleaner.strip, compile(rule.Strip)...)
+	}
+	return cleaner
+}
+
+// CleanDocument removes the elements matched by selector rules and the
+// paragraphs matched by paragraph rules from doc.
+func (c *Cleaner) CleanDocument(doc *goquery.Document) {
+	for _, selector := range c.selectors {
+		matched := doc.Find(selector)
+		if matched.Length() > 0 {
+			c.Stripped["selector "+selector] += matched.Length()
+			matched.Remove()
+		}
+	}
+	if len(c.paragraphs) == 0 {
+		return
+	}
+	doc.Find(paragraphSelector).Each(func(i int, s *goquery.Selection) {
+		// skip elements inside a paragraph removed earlier
+		if s.Closest("html").Length() == 0 {
+			return
+		}
+		text := strings.TrimSpace(s.Text())
+		if text == "" {
+			return
+		}
+		for _, rule := range c.paragraphs {
+			if rule.re.MatchString(text) {
+				c.Stripped["paragraph "+rule.source]++
+				s.Remove()
+				return
+			}
+		}
+	})
+}
+
+// CleanText removes the fragments matched by strip rules from text.
+func (c *Cleaner) CleanText(text string) string {
+	for _, rule := range c.strip {
+		matches := rule.re.FindAllStringIndex(text, -1)
+		if len(matches) == 0 {
+			continue
+		}
+		c.Stripped["strip "+rule.source] += len(matches)
+		text = rule.re.ReplaceAllString(text, " ")
+	}
+	return strings.TrimSpace(whitespaceRun.ReplaceAllString(text, " "))
+}
+
+// Total is the number of fragments stripped so far.
+func (c *Cleaner) Total() int {
+	total := 0
+	for _, count := range c.Stripped {
+		total += count
+	}
+	return total
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"resty.dev/v3"
+)
+
+const (
+	defaultCrawlConcurrency   = 8
+	defaultPerHostConcurrency = 2
+)
+
+// NewCrawlerClient builds the HTTP client used for feeds and article pages.
+// Requests identify the crawler, go through the proxy and politeness layers
+// and, unless HTTP_CACHE is false, the on-disk HTTP cache which is returned
+// as well. Cache entries older than HTTP_CACHE_MAX_AGE are removed.
+func NewCrawlerClient(ctx context.Context) (*resty.Client, *HTTPCache, error) {
+	client := resty.New().
+		SetTimeout(envDuration("REQUEST_TIMEOUT", 30*time.Second)).
+		SetRetryCount(3).
+		SetRetryWaitTime(2*time.Second).
+		SetRetryMaxWaitTime(10*time.Second).
+		SetHeader("User-Agent", userAgent())
+	base, ok := client.Transport().(*http.Transport)
+	if !ok {
+		return nil, nil, errors.New("unexpected resty transport")
+	}
+	proxies, err := NewProxyTransport(ctx, base, config.Network)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var transport http.RoundTripper = NewPoliteTransport(
+		proxies,
+		envFloat("CRAWL_HOST_RATE", defaultHostRate),
+		envDuration("CRAWL_MIN_DELAY", defaultHostMinDelay),
+		envBool("CRAWL_RESPECT_ROBOTS", true),
+	)
+
+	var cache *HTTPCache
+	if envBool("HTTP_CACHE", true) {
+		dir := os.Getenv("HTTP_CACHE_DIR")
+		if dir == "" {
+			dir = "./.cache/http"
+		}
+		cache, err = NewHTTPCache(dir, transport, envDuration("HTTP_CACHE_MAX_AGE", defaultHTTPCacheMaxAge))
+		if err != nil {
+			return nil, nil, err
+		}
+		transport = cache
+	}
+
+	return client.SetTransport(transport), cache, nil
+}
+
+type articleJob struct {
+	ctx     context.Context
+	target  string
+	item    FeedItem
+	results chan<- *CrawlerResult
+}
+
+// CrawlEngine fetches feed targets concurrently and extracts their articles
+// through a bounded pool of workers. At most `concurrency` article pages are
+// fetched at once, and at most `perHost` from the same host.
+type CrawlEngine struct {
+	client      *resty.Client
+	cache       *HTTPCache
+	concurrency int
+	perHost     int
+	jobs        chan articleJob
+	workers     sync.WaitGroup
+
+	mu    sync.Mutex
+	hosts map[string]chan struct{}
+}
+
+func NewCrawlEngine(client *resty.Client, cache *HTTPCache, concurrency int, perHost int) *CrawlEngine {
+	if concurrency <= 0 {
+		concurrency = defaultCrawlConcurrency
+	}
+	if perHost <= 0 {
+		perHost = defaultPerHostConcurrency
+	}
+	engine := &CrawlEngine{
+		client:      client,
+		cache:       cache,
+		concurrency: concurrency,
+		perHost:     perHost,
+		jobs:        make(chan articleJob),
+		hosts:       map[string]chan struct{}{},
+	}
+	for range concurrency {
+		engine.workers.Add(1)
+		go engine.worker()
+	}
+	return engine
+}
+
+// Close stops the workers once every submitted job is done.
+func (e *CrawlEngine) Close() {
+	close(e.jobs)
+	e.workers.Wait()
+}
+
+func (e *CrawlEngine) hostSemaphore(link string) chan struct{} {
+	host := link
+	if parsed, err := url.Parse(link); err == nil {
+		host = parsed.Hostname()
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	semaphore, ok := e.hosts[host]
+	if !ok {
+		semaphore = make(chan struct{}, e.perHost)
+		e.hosts[host] = semaphore
+	}
+	return semaphore
+}
+
+func (e *CrawlEngine) worker() {
+	defer e.workers.Done()
+	for job := range e.jobs {
+		job.results <- e.extract(job)
+	}
+}
+
+func (e *CrawlEngine) extract(job articleJob) *CrawlerResult {
+	semaphore := e.hostSemaphore(job.item.Link)
+	select {
+	case semaphore <- struct{}{}:
+	case <-job.ctx.Done():
+		return nil
+	}
+	defer func() { <-semaphore }()
+
+	result, err := ExtractArticle(job.ctx, job.target, job.item, e.client)
+	if err != nil && job.ctx.Err() != nil {
+		logger.Warn("Article cancelled by the crawl deadline", "url", job.item.Link, "error", err)
+		return nil
+	}
+	if err != nil {
+		logger.Error("Error extracting article", "url", job.item.Link, "error", err)
+		return nil
+	}
+	return result
+}
+
+// CrawlTarget fetches a feed and extracts its articles through the worker
+// pool. When ctx is done the articles extracted so far are returned.
+func (e *CrawlEngine) CrawlTarget(ctx context.Context, target string) (_ []CrawlerResult, err error) {
+	ctx, span := startSpan(ctx, "crawler.target", attribute.String("url", target))
+	defer func() { endSpan(span, err) }()
+
+	response, err := FetchFeed(ctx, target, e.client)
+	if errors.Is(err, errFeedNotModified) {
+		logger.Info("Feed not modified, skipping", "url", target)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if e.cache != nil && e.cache.FeedUnchanged(target, response.CachedAt) {
+		logger.Info("Feed cached_at unchanged, skipping", "url", target, "cached_at", response.CachedAt)
+		reportFromContext(ctx).TargetSkipped(target, "cached_at")
+		return nil, nil
+	}
+
+	results := make(chan *CrawlerResult, len(response.Items))
+	submitted := 0
+submit:
+	for _, item := range response.Items {
+		select {
+		case e.jobs <- articleJob{ctx: ctx, target: target, item: item, results: results}:
+			submitted++
+		case <-ctx.Done():
+			logger.Warn("Stopping target early", "url", target, "error", ctx.Err())
+			break submit
+		}
+	}
+
+	var articles []CrawlerResult
+	for range submitted {
+		if result := <-results; result != nil {
+			articles = append(articles, *result)
+		}
+	}
+	// keep what was extracted so far when the crawl stage runs out of time,
+	// whether the rest was never submitted or cancelled while being fetched,
+	// and fetch the feed again with the next run
+	if ctx.Err() != nil && e.cache != nil {
+		e.cache.Forget(target)
+	}
+
+	span.SetAttributes(attribute.Int("articles", len(articles)))
+	return articles, nil
+}
+
+// Run crawls every target concurrently and collects their articles.
+func (e *CrawlEngine) Run(ctx context.Context, targets []string) []CrawlerResult {
+	results := make(chan []CrawlerResult, len(targets))
+	var wg sync.WaitGroup
+	for _, target := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			articles, err := e.CrawlTarget(ctx, target)
+			if err != nil {
+				logger.Error("Error fetching URL", "url", target, "error", err)
+				reportFromContext(ctx).RecordError(err)
+				return
+			}
+			results <- articles
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	var rawArticles []CrawlerResult
+	for articles := range results {
+		rawArticles = append(rawArticles, articles...)
+	}
+	return rawArticles
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"resty.dev/v3"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestCrawlTargetDeadlineAfterSubmit(t *testing.T) {
+	const target = "https://feeds.example.com/detik"
+	cachedAt := time.Date(2025, 5, 12, 7, 0, 0, 0, time.UTC)
+	feed := `{"cached_at":"` + cachedAt.Format(time.RFC3339) + `","items":[
+		{"title":"Banjir","link":"https://news.detik.com/berita/d-1/banjir"},
+		{"title":"Pilkada","link":"https://news.detik.com/berita/d-2/pilkada"}]}`
+	fetching := make(chan struct{}, 2)
+	// the feed is served at once, article pages only answer when their
+	// request is cancelled
+	base := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.String() == target {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": {"application/json"}},
+				Body:       io.NopCloser(strings.NewReader(feed)),
+				Request:    req,
+			}, nil
+		}
+		fetching <- struct{}{}
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	})
+
+	dir := t.TempDir()
+	cache, err := NewHTTPCache(dir, base, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := resty.New().SetTransport(cache)
+	defer client.Close()
+	engine := NewCrawlEngine(client, cache, 4, 4)
+
+	report := NewRunReport(1)
+	ctx, cancel := context.WithCancel(withRunReport(t.Context(), report))
+	go func() {
+		// every item is being fetched before the deadline
+		<-fetching
+		<-fetching
+		cancel()
+	}()
+	articles, err := engine.CrawlTarget(ctx, target)
+	engine.Close()
+	if err != nil || len(articles) != 0 {
+		t.Fatalf("crawled %d articles: %v", len(articles), err)
+	}
+	// cancelled pages are not extraction failures
+	if len(report.ExtractionFailures) != 0 || len(report.Publishers) != 0 {
+		t.Errorf("cancelled pages reported as failures: %v, %v", report.ExtractionFailures, report.Publishers)
+	}
+	cache.Commit()
+
+	cache, _ = NewHTTPCache(dir, base, 0)
+	if cache.FeedUnchanged(target, cachedAt) {
+		t.Errorf("feed seen as unchanged although its items were cancelled")
+	}
+}
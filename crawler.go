@@ -1,254 +1,275 @@
 package main
 
 import (
-	"regexp"
+	"context"
+	"errors"
+	"net/url"
 	"strings"
 	"time"
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/godruoyi/go-snowflake"
+	"go.opentelemetry.io/otel/attribute"
 	"resty.dev/v3"
 )
 
 type Response struct {
-	AvailableCategories []string  `json:"available_categories"`
-	CachedAt            time.Time `json:"cached_at"`
-	Items               []struct {
-		Title       string    `json:"title"`
-		Link        string    `json:"link"`
-		Description string    `json:"description"`
-		PublishedAt time.Time `json:"published_at"`
-		Thumbnail   string    `json:"thumbnail"`
-	} `json:"items"`
+	AvailableCategories []string   `json:"available_categories"`
+	CachedAt            time.Time  `json:"cached_at"`
+	Items               []FeedItem `json:"items"`
 }
 
-type CrawlerResult struct {
-	ID          int64     `json:"id"`
+type FeedItem struct {
 	Title       string    `json:"title"`
-	Content     string    `json:"content"`
 	Link        string    `json:"link"`
+	Description string    `json:"description"`
 	PublishedAt time.Time `json:"published_at"`
-	Source      string    `json:"source"`
+	Thumbnail   string    `json:"thumbnail"`
 }
 
+type CrawlerResult struct {
+	ID          int64           `json:"id"`
+	Title       string          `json:"title"`
+	Content     string          `json:"content"`
+	Link        string          `json:"link"`
+	PublishedAt time.Time       `json:"published_at"`
+	Source      string          `json:"source"`
+	Author      string          `json:"author,omitempty"`
+	Section     string          `json:"section,omitempty"`
+	Keywords    []string        `json:"keywords,omitempty"`
+	ImageURL    string          `json:"image_url,omitempty"`
+	ModifiedAt  time.Time       `json:"modified_at,omitzero"`
+	PageType    PageType        `json:"page_type"`
+	Entries     []LiveBlogEntry `json:"entries,omitempty"`
+	Language    string          `json:"language"`
+	Thumbnail   string          `json:"-"`
+	// Target is the feed the article was listed in.
+	Target string   `json:"-"`
+	Images []string `json:"-"`
+}
+
+// ArticlePage is the content, metadata and images extracted from an article
+// page.
+type ArticlePage struct {
+	Type     PageType
+	Entries  []LiveBlogEntry
+	Content  string
+	Metadata ArticleMetadata
+	Images   []string
+}
+
+// publisherDomains maps the registered domains of the publishers to their
+// display names. Subdomains such as nasional.kompas.com belong to the same
+// publisher.
+var publisherDomains = map[string]string{
+	"kompas.com":        "Kompas",
+	"cnnindonesia.com":  "CNN",
+	"liputan6.com":      "Liputan6",
+	"kumparan.com":      "Kumparan",
+	"cnbcindonesia.com": "CNBC",
+	"detik.com":         "Detik",
+	"tempo.co":          "Tempo",
+	"antaranews.com":    "Antara",
+	"tribunnews.com":    "Tribun",
+}
+
+// normalizeSource returns the display name of the publisher of a URL or
+// host name, matched on the host so words in the path are never taken for
+// a publisher. Unknown sources are returned as is.
 func normalizeSource(source string) string {
-	switch {
-	case strings.Contains(source, "kompas"):
-		return "Kompas"
-	case strings.Contains(source, "cnn"):
-		return "CNN"
-	case strings.Contains(source, "liputan6"):
-		return "Liputan6"
-	case strings.Contains(source, "kumparan"):
-		return "Kumparan"
-	case strings.Contains(source, "cnbc"):
-		return "CNBC"
-	default:
-		return source
+	host := source
+	if parsed, err := url.Parse(source); err == nil && parsed.Host != "" {
+		host = parsed.Hostname()
+	}
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	for {
+		if publisher, ok := publisherDomains[host]; ok {
+			return publisher
+		}
+		_, parent, ok := strings.Cut(host, ".")
+		if !ok {
+			return source
+		}
+		host = parent
 	}
 }
 
-func StartCrawler(url string, client *resty.Client) (*[]CrawlerResult, error) {
+// errFeedNotModified is returned by FetchFeed when the HTTP cache served the
+// feed unchanged since the last run that processed it.
+var errFeedNotModified = errors.New("feed not modified")
+
+// FetchFeed downloads the list of items of a feed target.
+func FetchFeed(ctx context.Context, url string, client *resty.Client) (*Response, error) {
 	var response Response
-	_, err := client.R().
+	start := time.Now()
+	resp, err := client.R().
+		SetContext(withDeferredCache(ctx)).
 		SetResult(&response).
 		Get(url)
+	observeFeedFetch(url, start, resp, err)
 	if err != nil {
-		logger.Error("Error fetching URL [1]", "url", url, "error", err)
-		return nil, err
+		err = &FeedFetchError{URL: url, Err: err}
+	} else if resp.IsError() {
+		err = &FeedFetchError{URL: url, StatusCode: resp.StatusCode(), Err: errors.New(resp.Status())}
 	}
-
-	var results []CrawlerResult
-	for _, post := range response.Items {
-		var content *string
-		switch {
-		case strings.Contains(post.Link, "kompas"):
-			content, err = GetContentKompas(post.Link+"?page=all", client)
-			if err != nil {
-				logger.Error("Error fetching content [3]", "url", post.Link, "error", err)
-				continue
-			}
-		case strings.Contains(post.Link, "liputan6"):
-			content, err = GetContentLiputan6(post.Link, client)
-			if err != nil {
-				logger.Error("Error fetching content [3]", "url", post.Link, "error", err)
-				continue
-			}
-		case strings.Contains(post.Link, "cnbc"):
-			content, err = GetContentCNBC(post.Link, client)
-			if err != nil {
-				logger.Error("Error fetching content [3]", "url", post.Link, "error", err)
-				continue
-			}
-		case strings.Contains(post.Link, "cnn"):
-			content, err = GetContentCNN(post.Link, client)
-			if err != nil {
-				logger.Error("Error fetching content [3]", "url", post.Link, "error", err)
-				continue
-			}
-		case strings.Contains(post.Link, "kumparan"):
-			content, err = GetContentKumparan(post.Link+"/full", client)
-			if err != nil {
-				logger.Error("Error fetching content [3]", "url", post.Link, "error", err)
-				continue
-			}
+	if err == nil {
+		if status := resp.Header().Get(cacheStatusHeader); status == CacheHit || status == CacheRevalidated {
+			reportFromContext(ctx).TargetSkipped(url, status)
+			return nil, errFeedNotModified
 		}
-
-		// omit short content
-		if len(*content) < 100 {
-			continue
-		}
-
-		id := int64(snowflake.ID())
-		results = append(results, CrawlerResult{
-			ID:          id,
-			Title:       post.Title,
-			Content:     *content,
-			Link:        post.Link,
-			Source:      normalizeSource(post.Link),
-			PublishedAt: post.PublishedAt,
-		})
 	}
-
-	return &results, nil
-}
-
-func GetContentKompas(url string, client *resty.Client) (*string, error) {
-	logger.Info("--> Processing URL", "url", url)
-	response, err := client.R().
-		Get(url)
+	reportFromContext(ctx).TargetFetched(url, len(response.Items), err)
 	if err != nil {
-		logger.Error("Error fetching content [1]", "url", url, "error", err)
 		return nil, err
 	}
+	return &response, nil
+}
 
-	doc, err := goquery.NewDocumentFromReader(response.Body)
-	if err != nil {
-		logger.Error("Error fetching content [2]", "url", url, "error", err)
+// ExtractArticle fetches the article page of a feed item and extracts its
+// content. Articles that cannot be used are recorded in the run report and
+// returned as an error.
+func ExtractArticle(ctx context.Context, target string, post FeedItem, client *resty.Client) (*CrawlerResult, error) {
+	report := reportFromContext(ctx)
+	publisher := normalizeSource(post.Link)
+
+	page, err := GetContent(ctx, post.Link, client)
+	if err != nil && ctx.Err() != nil {
+		// pages cancelled by the crawl deadline say nothing about the
+		// extractor and are left out of its health
 		return nil, err
 	}
-
-	var content string
-	doc.Find(".read__content p").Each(func(i int, s *goquery.Selection) {
-		if strings.Contains(s.Text(), "Baca juga") {
-			return
-		}
-		content += s.Text() + " "
-	})
-
-	re := regexp.MustCompile(`\s{2,}`)
-	cleaned := re.ReplaceAllString(content, " ")
-	trimmed := strings.TrimSpace(cleaned)
-	return &trimmed, nil
-}
-
-func GetContentLiputan6(url string, client *resty.Client) (*string, error) {
-	logger.Info("--> Processing URL", "url", url)
-	response, err := client.R().
-		Get(url)
 	if err != nil {
-		logger.Error("Error fetching content [1]", "url", url, "error", err)
+		articlesDropped.WithLabelValues(publisher, "fetch_error").Inc()
+		report.ArticleDropped(target, err)
+		report.ContentExtracted(publisher, -1, false)
 		return nil, err
 	}
 
-	doc, err := goquery.NewDocumentFromReader(response.Body)
-	if err != nil {
-		logger.Error("Error fetching content [2]", "url", url, "error", err)
+	if pageActionFor(page.Type) == PageActionSkip {
+		err := &SkippedPageError{URL: post.Link, Publisher: publisher, PageType: page.Type}
+		articlesDropped.WithLabelValues(publisher, "page_"+string(page.Type)).Inc()
+		report.ArticleDropped(target, err)
 		return nil, err
 	}
 
-	var content string
-	doc.Find(".article-content-body__item-content p").Each(func(i int, s *goquery.Selection) {
-		content += s.Text() + " "
-	})
-
-	re := regexp.MustCompile(`\s{2,}`)
-	cleaned := re.ReplaceAllString(content, " ")
-	trimmed := strings.TrimSpace(cleaned)
-	return &trimmed, nil
-}
-
-func GetContentCNBC(url string, client *resty.Client) (*string, error) {
-	logger.Info("--> Processing URL", "url", url)
-	response, err := client.R().
-		Get(url)
-	if err != nil {
-		logger.Error("Error fetching content [1]", "url", url, "error", err)
+	// omit short content
+	report.ContentExtracted(publisher, len(page.Content), len(page.Content) >= 100)
+	if len(page.Content) < 100 {
+		err := &EmptyContentError{URL: post.Link, Publisher: publisher, Length: len(page.Content)}
+		articlesDropped.WithLabelValues(publisher, "short_content").Inc()
+		report.ArticleDropped(target, err)
 		return nil, err
 	}
+	articlesExtracted.WithLabelValues(publisher).Inc()
+	report.ArticleExtracted(target)
 
-	doc, err := goquery.NewDocumentFromReader(response.Body)
-	if err != nil {
-		logger.Error("Error fetching content [2]", "url", url, "error", err)
-		return nil, err
+	// the page's own timestamps are more precise than the feed's
+	publishedAt := post.PublishedAt
+	if !page.Metadata.PublishedAt.IsZero() {
+		publishedAt = page.Metadata.PublishedAt
 	}
 
-	var content string
-	doc.Find(".detail-text p").Each(func(i int, s *goquery.Selection) {
-		// ignore if it contains `linksisip` class
-		if s.HasClass("linksisip") {
-			return
-		}
-		content += s.Text() + " "
-	})
+	language, confidence := DetectLanguage(page.Content)
+	if language != LanguageIndonesian {
+		logger.Debug("Non-Indonesian article", "url", post.Link, "language", language, "confidence", confidence)
+	}
 
-	re := regexp.MustCompile(`\s{2,}`)
-	cleaned := re.ReplaceAllString(content, " ")
-	trimmed := strings.TrimSpace(cleaned)
-	return &trimmed, nil
+	return &CrawlerResult{
+		ID:          int64(snowflake.ID()),
+		Title:       post.Title,
+		Content:     page.Content,
+		Link:        post.Link,
+		Source:      publisher,
+		PublishedAt: publishedAt,
+		Author:      page.Metadata.Author,
+		Section:     page.Metadata.Section,
+		Keywords:    page.Metadata.Keywords,
+		ImageURL:    page.Metadata.ImageURL,
+		ModifiedAt:  page.Metadata.ModifiedAt,
+		PageType:    page.Type,
+		Entries:     page.Entries,
+		Language:    language,
+		Thumbnail:   post.Thumbnail,
+		Images:      page.Images,
+		Target:      target,
+	}, nil
 }
 
-func GetContentCNN(url string, client *resty.Client) (*string, error) {
-	logger.Info("--> Processing URL", "url", url)
+// fetchDocument downloads and parses an article page.
+func fetchDocument(ctx context.Context, url string, client *resty.Client) (*goquery.Document, error) {
+	publisher := normalizeSource(url)
 	response, err := client.R().
+		SetContext(ctx).
 		Get(url)
 	if err != nil {
-		logger.Error("Error fetching content [1]", "url", url, "error", err)
-		return nil, err
+		return nil, &ArticleFetchError{URL: url, Publisher: publisher, Err: err}
+	}
+	if response.IsError() {
+		return nil, &ArticleFetchError{URL: url, Publisher: publisher, StatusCode: response.StatusCode(), Err: errors.New(response.Status())}
 	}
 
 	doc, err := goquery.NewDocumentFromReader(response.Body)
 	if err != nil {
-		logger.Error("Error fetching content [2]", "url", url, "error", err)
-		return nil, err
+		return nil, &ParseError{URL: url, Publisher: publisher, Err: err}
 	}
+	return doc, nil
+}
 
-	var content string
-	doc.Find(".detail-text p").Each(func(i int, s *goquery.Selection) {
-		// ignore if it contains `para_caption` class
-		if s.HasClass("para_caption") {
-			return
-		}
-		content += s.Text() + " "
-	})
+// ArticleExtractor reads the text of one publisher's article pages.
+type ArticleExtractor struct {
+	// Images selects the containers searched for in-article images.
+	Images  string
+	Content func(doc *goquery.Document) string
+}
 
-	re := regexp.MustCompile(`\s{2,}`)
-	cleaned := re.ReplaceAllString(content, " ")
-	trimmed := strings.TrimSpace(cleaned)
-	return &trimmed, nil
+// articleExtractors are keyed by publisher display name (see
+// normalizeSource). Inserts such as related links and captions are removed
+// beforehand by the cleaning stage.
+var articleExtractors = map[string]ArticleExtractor{
+	"Kompas":   {Images: ".photo__wrap, .read__content", Content: paragraphContent(".read__content p")},
+	"Liputan6": {Images: ".read-page--top-media, .article-content-body", Content: paragraphContent(".article-content-body__item-content p")},
+	"CNBC":     {Images: ".media_artikel, .detail-text", Content: paragraphContent(".detail-text p")},
+	"CNN":      {Images: ".detail-image, .detail-text", Content: paragraphContent(".detail-text p")},
+	"Kumparan": {Images: "[data-qa-id=story-image], [data-qa-id=image-content]", Content: paragraphContent("span[data-qa-id=story-paragraph]")},
+	"Detik":    {Images: ".detail__media, .detail__body-text", Content: paragraphContent(".detail__body-text > p")},
+	"Tempo":    {Images: ".foto-detail, .detail-in", Content: paragraphContent(".detail-in p")},
+	// Antara writes the body as text separated by line breaks
+	"Antara": {Images: ".wrap__article-detail-image, .wrap__article-detail-content", Content: containerContent(".wrap__article-detail-content")},
+	"Tribun": {Images: ".imgfull_div, .side-article", Content: paragraphContent(".side-article.txt-article p")},
 }
 
-func GetContentKumparan(url string, client *resty.Client) (*string, error) {
-	logger.Info("--> Processing URL", "url", url)
-	response, err := client.R().
-		Get(url)
-	if err != nil {
-		return nil, err
+// paragraphContent joins the text of the elements matched by selector.
+func paragraphContent(selector string) func(doc *goquery.Document) string {
+	return func(doc *goquery.Document) string {
+		var content string
+		doc.Find(selector).Each(func(i int, s *goquery.Selection) {
+			content += s.Text() + " "
+		})
+		return content
 	}
+}
 
-	doc, err := goquery.NewDocumentFromReader(response.Body)
-	if err != nil {
-		logger.Error("Error fetching content [2]", "url", url, "error", err)
-		return nil, err
+// containerContent returns the whole text of the first element matched by
+// selector.
+func containerContent(selector string) func(doc *goquery.Document) string {
+	return func(doc *goquery.Document) string {
+		container := doc.Find(selector).First()
+		container.Find("br").ReplaceWithHtml(" ")
+		return container.Text()
 	}
+}
 
-	var content string
-	doc.Find("span[data-qa-id=story-paragraph]").Each(func(i int, s *goquery.Selection) {
-		content += s.Text() + " "
-	})
+// GetContent fetches an article page and extracts it with the extractor of
+// its publisher.
+func GetContent(ctx context.Context, url string, client *resty.Client) (_ *ArticlePage, err error) {
+	publisher := normalizeSource(url)
+	ctx, span := startSpan(ctx, "crawler.article", attribute.String("url", url), attribute.String("publisher", publisher))
+	defer func() { endSpan(span, err) }()
 
-	re := regexp.MustCompile(`\s{2,}`)
-	cleaned := re.ReplaceAllString(content, " ")
-	trimmed := strings.TrimSpace(cleaned)
-	return &trimmed, nil
+	extractor, ok := articleExtractors[publisher]
+	if !ok {
+		return nil, &ParseError{URL: url, Publisher: publisher, Err: errors.New("no extractor for publisher")}
+	}
+	logger.Info("--> Processing URL", "url", url)
+	return fetchArticle(ctx, url, client, extractor)
 }
@@ -0,0 +1,32 @@
+package main
+
+import "testing"
+
+func TestNormalizeSource(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"https://nasional.kompas.com/read/2025/05/12/10150001/pemerintah-umumkan-jadwal-libur-nasional", "Kompas"},
+		{"https://news.detik.com/berita/d-7900001/kpu-tetapkan-jadwal-pilkada-serentak", "Detik"},
+		{"https://www.cnbcindonesia.com/market/20250512091500-17-600001/ihsg-dibuka-menguat", "CNBC"},
+		{"https://www.cnnindonesia.com/nasional/20250512120000-20-1200001/dpr-gelar-rapat", "CNN"},
+		{"https://WWW.TEMPO.CO./politik/mk-tolak-uji-materi", "Tempo"},
+		// words in the path are not publishers
+		{"https://www.tribunnews.com/nasional/2025/05/12/detik-detik-pelaku-diamankan", "Tribun"},
+		{"https://www.antaranews.com/berita/4800001/tempo-pembangunan-dipercepat", "Antara"},
+		{"https://megapolitan.kompas.com/read/2025/05/12/antara-hujan-dan-banjir", "Kompas"},
+		// host names without a scheme, as the proxy transport picks the publisher's pool
+		{"img.antaranews.com", "Antara"},
+		{"kumparan.com", "Kumparan"},
+		// look-alike domains
+		{"https://detik.com.example.org/berita", "https://detik.com.example.org/berita"},
+		{"https://notdetik.com/berita", "https://notdetik.com/berita"},
+		{"Reuters", "Reuters"},
+	}
+	for _, test := range tests {
+		if got := normalizeSource(test.source); got != test.want {
+			t.Errorf("normalizeSource(%q) = %q, want %q", test.source, got, test.want)
+		}
+	}
+}
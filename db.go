@@ -40,6 +40,231 @@ func initMigration(db *sql.DB) {
 	if err != nil {
 		logger.Debug("Error altering table articles", "error", err)
 	}
+
+	logger.Debug("running migration 3")
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+			id BIGINT PRIMARY KEY NOT NULL,
+			url TEXT NOT NULL,
+			secret TEXT NOT NULL,
+			events TEXT NOT NULL, -- comma separated
+			active INTEGER NOT NULL DEFAULT 1,
+			created_at TEXT NOT NULL
+		);
+	`)
+	if err != nil {
+		logger.Error("Error creating webhook tables", "error", err)
+		os.Exit(1)
+	}
+
+	// the driver runs a single statement per Exec
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS webhook_deliveries (
+			id BIGINT PRIMARY KEY NOT NULL,
+			subscription_id BIGINT NOT NULL,
+			event_id BIGINT NOT NULL,
+			event TEXT NOT NULL,
+			attempt INTEGER NOT NULL,
+			status_code INTEGER NOT NULL,
+			error TEXT NOT NULL,
+			created_at TEXT NOT NULL
+		);
+	`)
+	if err != nil {
+		logger.Error("Error creating webhook tables", "error", err)
+		os.Exit(1)
+	}
+	_, err = db.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_subscription_id ON webhook_deliveries(subscription_id);
+	`)
+	if err != nil {
+		logger.Error("Error creating webhook tables", "error", err)
+		os.Exit(1)
+	}
+
+	logger.Debug("running migration 4")
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS crawl_runs (
+			id BIGINT PRIMARY KEY NOT NULL,
+			started_at TEXT NOT NULL,
+			ended_at TEXT NOT NULL,
+			status TEXT NOT NULL,
+			error TEXT NOT NULL,
+			targets TEXT NOT NULL, -- json object keyed by target url
+			extraction_failures TEXT NOT NULL, -- json array
+			groups INTEGER NOT NULL,
+			summarization_failures TEXT NOT NULL, -- json array
+			inserted_article_ids TEXT NOT NULL, -- comma separated
+			ai_model TEXT NOT NULL,
+			prompt_tokens INTEGER NOT NULL,
+			candidates_tokens INTEGER NOT NULL,
+			total_tokens INTEGER NOT NULL
+		);
+	`)
+	if err != nil {
+		logger.Error("Error creating table crawl_runs", "error", err)
+		os.Exit(1)
+	}
+
+	logger.Debug("running migration 5")
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS extractor_health (
+			run_id BIGINT NOT NULL,
+			publisher TEXT NOT NULL,
+			attempts INTEGER NOT NULL,
+			successes INTEGER NOT NULL,
+			median_length INTEGER NOT NULL,
+			created_at TEXT NOT NULL,
+			PRIMARY KEY (run_id, publisher)
+		);
+	`)
+	if err != nil {
+		logger.Error("Error creating table extractor_health", "error", err)
+		os.Exit(1)
+	}
+
+	logger.Debug("running migration 6")
+	_, err = db.Exec(`
+		ALTER TABLE crawl_runs ADD COLUMN errors TEXT NOT NULL DEFAULT '[]'; -- json array
+	`)
+	if err != nil {
+		logger.Debug("Error altering table crawl_runs", "error", err)
+	}
+
+	logger.Debug("running migration 7")
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS raw_articles (
+			id INTEGER PRIMARY KEY,
+			run_id INTEGER NOT NULL,
+			title TEXT NOT NULL,
+			content TEXT NOT NULL,
+			link TEXT NOT NULL,
+			source TEXT NOT NULL,
+			author TEXT NOT NULL DEFAULT '',
+			section TEXT NOT NULL DEFAULT '',
+			keywords TEXT NOT NULL DEFAULT '', -- comma separated
+			image_url TEXT NOT NULL DEFAULT '',
+			published_at TEXT NOT NULL DEFAULT '',
+			modified_at TEXT NOT NULL DEFAULT '',
+			created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+	`)
+	if err != nil {
+		logger.Error("Error creating table raw_articles", "error", err)
+		os.Exit(1)
+	}
+	_, err = db.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_raw_articles_run_id ON raw_articles (run_id);
+	`)
+	if err != nil {
+		logger.Error("Error creating table raw_articles", "error", err)
+		os.Exit(1)
+	}
+
+	logger.Debug("running migration 8")
+	_, err = db.Exec(`
+		ALTER TABLE articles ADD COLUMN image TEXT NOT NULL DEFAULT ''; -- json object
+	`)
+	if err != nil {
+		logger.Debug("Error altering table articles", "error", err)
+	}
+
+	logger.Debug("running migration 9")
+	_, err = db.Exec(`
+		ALTER TABLE raw_articles ADD COLUMN page_type TEXT NOT NULL DEFAULT 'article';
+	`)
+	if err != nil {
+		logger.Debug("Error altering table raw_articles", "error", err)
+	}
+
+	logger.Debug("running migration 10")
+	_, err = db.Exec(`
+		ALTER TABLE raw_articles ADD COLUMN entries TEXT NOT NULL DEFAULT '[]'; -- json array of live blog entries
+	`)
+	if err != nil {
+		logger.Debug("Error altering table raw_articles", "error", err)
+	}
+
+	logger.Debug("running migration 11")
+	_, err = db.Exec(`
+		ALTER TABLE raw_articles ADD COLUMN language TEXT NOT NULL DEFAULT '';
+	`)
+	if err != nil {
+		logger.Debug("Error altering table raw_articles", "error", err)
+	}
+
+	logger.Debug("running migration 12")
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS article_translations (
+			article_id INTEGER NOT NULL,
+			language TEXT NOT NULL,
+			title TEXT NOT NULL,
+			excerpt TEXT NOT NULL,
+			long_content TEXT NOT NULL,
+			ai_model TEXT NOT NULL,
+			created_at TEXT NOT NULL,
+			PRIMARY KEY (article_id, language)
+		);
+	`)
+	if err != nil {
+		logger.Error("Error creating table article_translations", "error", err)
+		os.Exit(1)
+	}
+
+	logger.Debug("running migration 13")
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS prompt_versions (
+			name TEXT NOT NULL,
+			version TEXT NOT NULL,
+			source TEXT NOT NULL,
+			created_at TEXT NOT NULL,
+			PRIMARY KEY (name, version)
+		);
+	`)
+	if err != nil {
+		logger.Error("Error creating table prompt_versions", "error", err)
+		os.Exit(1)
+	}
+
+	logger.Debug("running migration 14")
+	_, err = db.Exec(`
+		ALTER TABLE articles ADD COLUMN prompt_version TEXT NOT NULL DEFAULT '';
+	`)
+	if err != nil {
+		logger.Debug("Error altering table articles", "error", err)
+	}
+
+	logger.Debug("running migration 15")
+	_, err = db.Exec(`
+		ALTER TABLE articles ADD COLUMN subcategory TEXT NOT NULL DEFAULT '';
+	`)
+	if err != nil {
+		logger.Debug("Error altering table articles", "error", err)
+	}
+
+	logger.Debug("running migration 16")
+	_, err = db.Exec(`
+		ALTER TABLE articles ADD COLUMN quality_flags TEXT NOT NULL DEFAULT '[]'; -- json array of failed checks
+	`)
+	if err != nil {
+		logger.Debug("Error altering table articles", "error", err)
+	}
+
+	logger.Debug("running migration 17")
+	_, err = db.Exec(`
+		ALTER TABLE prompt_versions ADD COLUMN settings TEXT NOT NULL DEFAULT ''; -- json of the taxonomy and paragraph bounds
+	`)
+	if err != nil {
+		logger.Debug("Error altering table prompt_versions", "error", err)
+	}
+
+	logger.Debug("running migration 18")
+	_, err = db.Exec(`
+		ALTER TABLE article_translations ADD COLUMN prompt_version TEXT NOT NULL DEFAULT '';
+	`)
+	if err != nil {
+		logger.Debug("Error altering table article_translations", "error", err)
+	}
 }
 
 func InitDB() (*sql.DB, func(), error) {
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+type Stage string
+
+const (
+	StageFeedFetch    Stage = "feed_fetch"
+	StageArticleFetch Stage = "article_fetch"
+	StageParse        Stage = "parse"
+	StageExtract      Stage = "extract"
+	StageLLM          Stage = "llm"
+	StageStore        Stage = "store"
+)
+
+// PipelineError is implemented by every typed error produced by the
+// pipeline so failures can be classified without parsing messages.
+type PipelineError interface {
+	error
+	Stage() Stage
+}
+
+type FeedFetchError struct {
+	URL        string
+	StatusCode int
+	Err        error
+}
+
+func (e *FeedFetchError) Error() string {
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("fetching feed %s: status %d: %v", e.URL, e.StatusCode, e.Err)
+	}
+	return fmt.Sprintf("fetching feed %s: %v", e.URL, e.Err)
+}
+
+func (e *FeedFetchError) Unwrap() error { return e.Err }
+func (e *FeedFetchError) Stage() Stage  { return StageFeedFetch }
+
+type ArticleFetchError struct {
+	URL        string
+	Publisher  string
+	StatusCode int
+	Err        error
+}
+
+func (e *ArticleFetchError) Error() string {
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("fetching %s article %s: status %d: %v", e.Publisher, e.URL, e.StatusCode, e.Err)
+	}
+	return fmt.Sprintf("fetching %s article %s: %v", e.Publisher, e.URL, e.Err)
+}
+
+func (e *ArticleFetchError) Unwrap() error { return e.Err }
+func (e *ArticleFetchError) Stage() Stage  { return StageArticleFetch }
+
+type ParseError struct {
+	URL       string
+	Publisher string
+	Err       error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("parsing %s article %s: %v", e.Publisher, e.URL, e.Err)
+}
+
+func (e *ParseError) Unwrap() error { return e.Err }
+func (e *ParseError) Stage() Stage  { return StageParse }
+
+type EmptyContentError struct {
+	URL       string
+	Publisher string
+	Length    int
+}
+
+func (e *EmptyContentError) Error() string {
+	return fmt.Sprintf("%s article %s has too little content (%d chars)", e.Publisher, e.URL, e.Length)
+}
+
+func (e *EmptyContentError) Stage() Stage { return StageExtract }
+
+// SkippedPageError reports a page that was fetched but is not handled as
+// an article, e.g. a video page.
+type SkippedPageError struct {
+	URL       string
+	Publisher string
+	PageType  PageType
+}
+
+func (e *SkippedPageError) Error() string {
+	return fmt.Sprintf("%s article %s is a %s page", e.Publisher, e.URL, e.PageType)
+}
+
+func (e *SkippedPageError) Stage() Stage { return StageExtract }
+
+type LLMError struct {
+	Operation string
+	Model     string
+	Err       error
+}
+
+func (e *LLMError) Error() string {
+	return fmt.Sprintf("%s with %s: %v", e.Operation, e.Model, e.Err)
+}
+
+func (e *LLMError) Unwrap() error { return e.Err }
+func (e *LLMError) Stage() Stage  { return StageLLM }
+
+type StoreError struct {
+	Operation string
+	Err       error
+}
+
+func (e *StoreError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Operation, e.Err)
+}
+
+func (e *StoreError) Unwrap() error { return e.Err }
+func (e *StoreError) Stage() Stage  { return StageStore }
+
+// ErrorRecord is the serializable form of a pipeline error kept in the run
+// report.
+type ErrorRecord struct {
+	Type       string `json:"type"`
+	Stage      Stage  `json:"stage"`
+	URL        string `json:"url,omitempty"`
+	Publisher  string `json:"publisher,omitempty"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Message    string `json:"reason"`
+}
+
+func NewErrorRecord(err error) ErrorRecord {
+	record := ErrorRecord{Type: "Error", Message: err.Error()}
+
+	var feedErr *FeedFetchError
+	var articleErr *ArticleFetchError
+	var parseErr *ParseError
+	var emptyErr *EmptyContentError
+	var skippedErr *SkippedPageError
+	var llmErr *LLMError
+	var storeErr *StoreError
+	switch {
+	case errors.As(err, &feedErr):
+		record.Type = "FeedFetchError"
+		record.URL = feedErr.URL
+		record.StatusCode = feedErr.StatusCode
+	case errors.As(err, &articleErr):
+		record.Type = "ArticleFetchError"
+		record.URL = articleErr.URL
+		record.Publisher = articleErr.Publisher
+		record.StatusCode = articleErr.StatusCode
+	case errors.As(err, &parseErr):
+		record.Type = "ParseError"
+		record.URL = parseErr.URL
+		record.Publisher = parseErr.Publisher
+	case errors.As(err, &emptyErr):
+		record.Type = "EmptyContentError"
+		record.URL = emptyErr.URL
+		record.Publisher = emptyErr.Publisher
+	case errors.As(err, &skippedErr):
+		record.Type = "SkippedPageError"
+		record.URL = skippedErr.URL
+		record.Publisher = skippedErr.Publisher
+	case errors.As(err, &llmErr):
+		record.Type = "LLMError"
+	case errors.As(err, &storeErr):
+		record.Type = "StoreError"
+	}
+
+	var pipelineErr PipelineError
+	if errors.As(err, &pipelineErr) {
+		record.Stage = pipelineErr.Stage()
+	}
+	return record
+}
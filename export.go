@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"embed"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+)
+
+//go:embed templates/*.tmpl
+var templateFiles embed.FS
+
+var siteTemplates = template.Must(template.New("").Funcs(template.FuncMap{
+	"content": func(article Article) template.HTML {
+		return template.HTML(longContentHTML(article.LongContent))
+	},
+	"date": func(t time.Time) string {
+		return t.Format("02 Jan 2006 15:04")
+	},
+	"htmlPage": func(name string) string {
+		return strings.TrimSuffix(name, ".json") + ".html"
+	},
+	"articleURL": articleURL,
+	"join":       strings.Join,
+	"articlePath": func(article Article) string {
+		return editionPath(article.Language, "/articles/"+strconv.FormatInt(article.ID, 10)+"/")
+	},
+	"editionPath":   editionPath,
+	"categoryLabel": categoryLabel,
+	"label": func(language string, key string) string {
+		return siteLabels[editionLanguage(language)][key]
+	},
+	"page": func(title string, language string) PageHeader {
+		return PageHeader{Title: title, Language: editionLanguage(language)}
+	},
+}).ParseFS(templateFiles, "templates/*.html.tmpl"))
+
+// siteLabels are the fixed texts of the HTML pages per edition language.
+var siteLabels = map[string]map[string]string{
+	LanguageIndonesian: {
+		"latest":  "Berita Terkini",
+		"all":     "Semua",
+		"empty":   "Belum ada berita.",
+		"prev":    "Sebelumnya",
+		"next":    "Berikutnya",
+		"sources": "Sumber",
+	},
+	LanguageEnglish: {
+		"latest":  "Latest News",
+		"all":     "All",
+		"empty":   "No news yet.",
+		"prev":    "Previous",
+		"next":    "Next",
+		"sources": "Sources",
+	},
+}
+
+// PageHeader is passed to the shared header template.
+type PageHeader struct {
+	Title    string
+	Language string
+}
+
+// editionPath prefixes a site path with the language of its edition.
+func editionPath(language string, path string) string {
+	language = editionLanguage(language)
+	if language == LanguageIndonesian {
+		return path
+	}
+	return "/" + language + path
+}
+
+type ExportOptions struct {
+	Dir      string
+	Limit    int
+	PageSize int
+	HTML     bool
+	// Language is the edition to export; other than Indonesian it is
+	// written below a directory named after it, e.g. en/.
+	Language string
+}
+
+type IndexItem struct {
+	ID          int64     `json:"id"`
+	Title       string    `json:"title"`
+	Excerpt     string    `json:"excerpt"`
+	Category    string    `json:"category"`
+	Subcategory string    `json:"subcategory,omitempty"`
+	Sources     []string  `json:"sources"`
+	CreatedAt   time.Time `json:"created_at"`
+	Path        string    `json:"path"`
+}
+
+type IndexPage struct {
+	Language   string      `json:"language"`
+	Category   string      `json:"category,omitempty"`
+	Page       int         `json:"page"`
+	TotalPages int         `json:"total_pages"`
+	Next       string      `json:"next,omitempty"`
+	Prev       string      `json:"prev,omitempty"`
+	Items      []IndexItem `json:"items"`
+	Categories []string    `json:"-"`
+	Articles   []Article   `json:"-"`
+}
+
+func indexPageName(page int, ext string) string {
+	if page == 1 {
+		return "index" + ext
+	}
+	return fmt.Sprintf("index-%d%s", page, ext)
+}
+
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("error creating directory for %s: %v", path, err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("error writing %s: %v", path, err)
+	}
+	return nil
+}
+
+func writeJSON(path string, value any) error {
+	output, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling %s: %v", path, err)
+	}
+	return writeFile(path, output)
+}
+
+func writeTemplate(path string, name string, data any) error {
+	var buf bytes.Buffer
+	if err := siteTemplates.ExecuteTemplate(&buf, name, data); err != nil {
+		return fmt.Errorf("error rendering %s: %v", name, err)
+	}
+	return writeFile(path, buf.Bytes())
+}
+
+// exportIndex writes the paginated index of articles into dir, both as JSON
+// and, when enabled, as HTML.
+func exportIndex(dir string, category string, articles []Article, opts ExportOptions) error {
+	chunks := lo.Chunk(articles, opts.PageSize)
+	if len(chunks) == 0 {
+		chunks = [][]Article{{}}
+	}
+	for i, chunk := range chunks {
+		page := IndexPage{
+			Language:   editionLanguage(opts.Language),
+			Category:   category,
+			Page:       i + 1,
+			TotalPages: len(chunks),
+			Items:      []IndexItem{},
+			Categories: categories(),
+			Articles:   chunk,
+		}
+		if i > 0 {
+			page.Prev = indexPageName(i, ".json")
+		}
+		if i < len(chunks)-1 {
+			page.Next = indexPageName(i+2, ".json")
+		}
+		for _, article := range chunk {
+			page.Items = append(page.Items, IndexItem{
+				ID:          article.ID,
+				Title:       article.Title,
+				Excerpt:     article.Excerpt,
+				Category:    article.Category,
+				Subcategory: article.Subcategory,
+				Sources:     article.Sources,
+				CreatedAt:   article.CreatedAt,
+				Path:        editionPath(opts.Language, fmt.Sprintf("/articles/%d.json", article.ID)),
+			})
+		}
+
+		if err := writeJSON(filepath.Join(dir, indexPageName(page.Page, ".json")), page); err != nil {
+			return err
+		}
+		if opts.HTML {
+			if err := writeTemplate(filepath.Join(dir, indexPageName(page.Page, ".html")), "index.html.tmpl", page); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Export writes the latest articles as static JSON files (and optionally
+// HTML pages) so the site can be served without a live database.
+func Export(db *sql.DB, opts ExportOptions) error {
+	articles, err := ListArticles(db, ArticleQuery{Limit: opts.Limit, Language: opts.Language})
+	if err != nil {
+		return err
+	}
+	if language := editionLanguage(opts.Language); language != LanguageIndonesian {
+		opts.Dir = filepath.Join(opts.Dir, language)
+	}
+	logger.Info("Exporting articles", "dir", opts.Dir, "language", editionLanguage(opts.Language), "articles", len(articles))
+
+	if err := exportIndex(opts.Dir, "", articles, opts); err != nil {
+		return err
+	}
+	for _, category := range categories() {
+		categoryArticles := lo.Filter(articles, func(article Article, _ int) bool {
+			return article.Category == category
+		})
+		if err := exportIndex(filepath.Join(opts.Dir, "categories", category), category, categoryArticles, opts); err != nil {
+			return err
+		}
+	}
+
+	for _, article := range articles {
+		if err := writeJSON(filepath.Join(opts.Dir, "articles", fmt.Sprintf("%d.json", article.ID)), article); err != nil {
+			return err
+		}
+		if opts.HTML {
+			path := filepath.Join(opts.Dir, "articles", strconv.FormatInt(article.ID, 10), "index.html")
+			if err := writeTemplate(path, "article.html.tmpl", article); err != nil {
+				return err
+			}
+		}
+	}
+
+	return ExportFeeds(db, filepath.Join(opts.Dir, "feeds"), opts.Language, defaultFeedLimit)
+}
+
+func runExport(db *sql.DB, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	opts := ExportOptions{}
+	flags.StringVar(&opts.Dir, "out", "./public", "directory to write the static site to")
+	flags.IntVar(&opts.Limit, "limit", 200, "number of latest articles to export")
+	flags.IntVar(&opts.PageSize, "page-size", 20, "number of articles per index page")
+	flags.BoolVar(&opts.HTML, "html", false, "also render HTML pages")
+	flags.StringVar(&opts.Language, "lang", "", "edition language to export, e.g. en (default Indonesian)")
+	flags.Parse(args)
+
+	if !validEdition(opts.Language) {
+		return fmt.Errorf("unsupported lang %q", opts.Language)
+	}
+	if opts.PageSize <= 0 {
+		return fmt.Errorf("page-size must be positive")
+	}
+	return Export(db, opts)
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	EventExtractorDegraded = "extractor.degraded"
+
+	// healthBaselineRuns is the number of previous runs the baseline is
+	// computed from.
+	healthBaselineRuns = 10
+	// healthMinimumBaseline is the number of previous runs required before
+	// alerts are raised.
+	healthMinimumBaseline = 3
+	// healthMinimumAttempts avoids alerting on publishers with only a couple
+	// of items in the run.
+	healthMinimumAttempts = 3
+	// healthDegradedRatio is the fraction of the baseline below which a
+	// publisher is considered degraded.
+	healthDegradedRatio = 0.5
+)
+
+type PublisherStats struct {
+	Attempts  int
+	Successes int
+	Lengths   []int
+}
+
+type ExtractorHealth struct {
+	RunID        int64   `json:"run_id"`
+	Publisher    string  `json:"publisher"`
+	Attempts     int     `json:"attempts"`
+	Successes    int     `json:"successes"`
+	SuccessRate  float64 `json:"success_rate"`
+	MedianLength int     `json:"median_length"`
+}
+
+type ExtractorAlert struct {
+	Current              ExtractorHealth `json:"current"`
+	BaselineSuccessRate  float64         `json:"baseline_success_rate"`
+	BaselineMedianLength int             `json:"baseline_median_length"`
+	Reasons              []string        `json:"reasons"`
+}
+
+// ContentExtracted records the outcome of one article extraction for the
+// publisher. length is -1 when the page could not be fetched.
+func (r *RunReport) ContentExtracted(publisher string, length int, success bool) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.Publishers == nil {
+		r.Publishers = map[string]*PublisherStats{}
+	}
+	stats, ok := r.Publishers[publisher]
+	if !ok {
+		stats = &PublisherStats{}
+		r.Publishers[publisher] = stats
+	}
+	stats.Attempts++
+	if success {
+		stats.Successes++
+	}
+	if length >= 0 {
+		stats.Lengths = append(stats.Lengths, length)
+	}
+}
+
+func median(values []int) int {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]int(nil), values...)
+	sort.Ints(sorted)
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
+
+func (r *RunReport) extractorHealth() []ExtractorHealth {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var health []ExtractorHealth
+	for publisher, stats := range r.Publishers {
+		health = append(health, ExtractorHealth{
+			RunID:        r.ID,
+			Publisher:    publisher,
+			Attempts:     stats.Attempts,
+			Successes:    stats.Successes,
+			SuccessRate:  float64(stats.Successes) / float64(stats.Attempts),
+			MedianLength: median(stats.Lengths),
+		})
+	}
+	sort.Slice(health, func(i, j int) bool {
+		return health[i].Publisher < health[j].Publisher
+	})
+	return health
+}
+
+func recentExtractorHealth(ctx context.Context, db *sql.DB, publisher string, before int64) ([]ExtractorHealth, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT run_id, publisher, attempts, successes, median_length
+		FROM extractor_health
+		WHERE publisher = ? AND run_id < ?
+		ORDER BY run_id DESC
+		LIMIT ?
+	`, publisher, before, healthBaselineRuns)
+	if err != nil {
+		return nil, fmt.Errorf("error querying extractor health: %v", err)
+	}
+	defer rows.Close()
+
+	var history []ExtractorHealth
+	for rows.Next() {
+		var health ExtractorHealth
+		if err := rows.Scan(&health.RunID, &health.Publisher, &health.Attempts, &health.Successes, &health.MedianLength); err != nil {
+			return nil, fmt.Errorf("error scanning extractor health: %v", err)
+		}
+		if health.Attempts > 0 {
+			health.SuccessRate = float64(health.Successes) / float64(health.Attempts)
+		}
+		history = append(history, health)
+	}
+	return history, rows.Err()
+}
+
+// compareToBaseline returns an alert when the current health is below the
+// rolling baseline, or nil when the publisher looks healthy.
+func compareToBaseline(current ExtractorHealth, history []ExtractorHealth) *ExtractorAlert {
+	if len(history) < healthMinimumBaseline || current.Attempts < healthMinimumAttempts {
+		return nil
+	}
+
+	var successRate float64
+	var lengths []int
+	for _, health := range history {
+		successRate += health.SuccessRate
+		lengths = append(lengths, health.MedianLength)
+	}
+	alert := &ExtractorAlert{
+		Current:              current,
+		BaselineSuccessRate:  successRate / float64(len(history)),
+		BaselineMedianLength: median(lengths),
+	}
+	if current.SuccessRate < alert.BaselineSuccessRate*healthDegradedRatio {
+		alert.Reasons = append(alert.Reasons, fmt.Sprintf("success rate %.0f%% is below baseline %.0f%%", current.SuccessRate*100, alert.BaselineSuccessRate*100))
+	}
+	if float64(current.MedianLength) < float64(alert.BaselineMedianLength)*healthDegradedRatio {
+		alert.Reasons = append(alert.Reasons, fmt.Sprintf("median content length %d is below baseline %d", current.MedianLength, alert.BaselineMedianLength))
+	}
+	if len(alert.Reasons) == 0 {
+		return nil
+	}
+	return alert
+}
+
+// CheckExtractorHealth compares each publisher's extraction results in the
+// run against its rolling baseline, alerts on degraded publishers and
+// stores the run's results for future baselines.
+func CheckExtractorHealth(ctx context.Context, db *sql.DB, report *RunReport, webhooks *WebhookDispatcher) error {
+	createdAt := time.Now().Format(createdAtLayout)
+	for _, current := range report.extractorHealth() {
+		history, err := recentExtractorHealth(ctx, db, current.Publisher, report.ID)
+		if err != nil {
+			return err
+		}
+		if alert := compareToBaseline(current, history); alert != nil {
+			logger.Warn("Extractor degraded", "publisher", current.Publisher, "reasons", alert.Reasons,
+				"success_rate", current.SuccessRate, "median_length", current.MedianLength)
+			webhooks.Dispatch(EventExtractorDegraded, alert)
+		}
+
+		_, err = db.ExecContext(ctx, `
+			INSERT INTO extractor_health (run_id, publisher, attempts, successes, median_length, created_at)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, current.RunID, current.Publisher, current.Attempts, current.Successes, current.MedianLength, createdAt)
+		if err != nil {
+			return fmt.Errorf("error inserting extractor health: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of the extractor fixtures")
+
+// fixtureResult is what the extraction layer reads from a saved article
+// page, stored next to the page as <name>.golden.json.
+type fixtureResult struct {
+	URL       string          `json:"url"`
+	Publisher string          `json:"publisher"`
+	PageType  PageType        `json:"page_type"`
+	Metadata  ArticleMetadata `json:"metadata"`
+	Images    []string        `json:"images,omitempty"`
+	Entries   []LiveBlogEntry `json:"entries,omitempty"`
+	Language  string          `json:"language"`
+	Content   string          `json:"content"`
+}
+
+// extractFixture runs the extractor of the publisher of articleURL over a
+// saved page, without following further pages.
+func extractFixture(t *testing.T, path string, articleURL string) *fixtureResult {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	doc, err := goquery.NewDocumentFromReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	publisher := normalizeSource(articleURL)
+	extractor, ok := articleExtractors[publisher]
+	if !ok {
+		t.Fatalf("no extractor for publisher %s", publisher)
+	}
+	cleaner := NewCleaner(publisher)
+	page := parseArticlePage(doc, articleURL, articleURL, extractor, cleaner)
+	content := cleaner.CleanText(page.Content)
+	language, _ := DetectLanguage(content)
+	return &fixtureResult{
+		URL:       articleURL,
+		Publisher: publisher,
+		PageType:  page.Type,
+		Metadata:  page.Metadata,
+		Images:    page.Images,
+		Entries:   page.Entries,
+		Language:  language,
+		Content:   content,
+	}
+}
+
+// TestExtractors checks the extractors against the saved pages in
+// testdata/fixtures. Only the built-in cleaning and page type rules are
+// used. Run with -update to rewrite the golden files.
+func TestExtractors(t *testing.T) {
+	cleaning, pageTypes := config.Cleaning, config.PageTypes
+	t.Cleanup(func() { config.Cleaning, config.PageTypes = cleaning, pageTypes })
+	config.Cleaning = nil
+	config.PageTypes = nil
+
+	tests := []struct {
+		name string
+		url  string
+	}{
+		{"antara", "https://www.antaranews.com/berita/4800001/bmkg-prakirakan-hujan-lebat-di-sejumlah-wilayah"},
+		{"cnbc", "https://www.cnbcindonesia.com/market/20250512091500-17-600001/ihsg-dibuka-menguat-di-awal-pekan"},
+		{"cnbc-video", "https://www.cnbcindonesia.com/market/video/20250512100000-600-600002/video-rupiah-melemah-tipis"},
+		{"cnn", "https://www.cnnindonesia.com/nasional/20250512120000-20-1200001/dpr-gelar-rapat-paripurna-pembukaan-masa-sidang"},
+		{"cnn-english", "https://www.cnnindonesia.com/internasional/20250512200000-134-1200002/central-banks-signal-slower-pace-of-rate-cuts"},
+		{"detik", "https://news.detik.com/berita/d-7900001/kpu-tetapkan-jadwal-pilkada-serentak"},
+		{"kompas", "https://nasional.kompas.com/read/2025/05/12/10150001/pemerintah-umumkan-jadwal-libur-nasional"},
+		{"kompas-liveblog", "https://www.kompas.com/live/2025/05/12/laporan-langsung-sidang-tahunan"},
+		{"kumparan", "https://kumparan.com/kumparannews/menteri-resmikan-jalan-tol-baru-di-sumatera-24abc"},
+		{"liputan6", "https://www.liputan6.com/news/read/5900001/banjir-rendam-ratusan-rumah-di-bekasi"},
+		{"tempo", "https://www.tempo.co/politik/mahkamah-konstitusi-tolak-uji-materi-undang-undang-pemilu-1200001"},
+		{"tribun", "https://www.tribunnews.com/nasional/2025/05/12/polisi-amankan-pelaku-pencurian-kendaraan"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			page := filepath.Join("testdata", "fixtures", test.name+".html")
+			goldenPath := filepath.Join("testdata", "fixtures", test.name+".golden.json")
+
+			actual, err := json.MarshalIndent(extractFixture(t, page, test.url), "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			actual = append(actual, '\n')
+			if *update {
+				if err := os.WriteFile(goldenPath, actual, 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			expected, err := os.ReadFile(goldenPath)
+			if errors.Is(err, os.ErrNotExist) {
+				t.Fatalf("missing %s, run with -update", goldenPath)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bytes.Equal(expected, actual) {
+				return
+			}
+			var want, got map[string]json.RawMessage
+			json.Unmarshal(expected, &want)
+			json.Unmarshal(actual, &got)
+			for field := range want {
+				if !bytes.Equal(want[field], got[field]) {
+					t.Errorf("%s:\n want %s\n got  %s", field, want[field], got[field])
+				}
+			}
+			for field := range got {
+				if _, ok := want[field]; !ok {
+					t.Errorf("unexpected field %s: %s", field, got[field])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,400 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+)
+
+const feedTitle = "Ngopibentar"
+
+var feedDescriptions = map[string]string{
+	LanguageIndonesian: "Ringkasan berita terkini dari berbagai media Indonesia",
+	LanguageEnglish:    "Summaries of the latest news from Indonesian media",
+}
+
+// sourcesLabels head the list of original links below each story.
+var sourcesLabels = map[string]string{
+	LanguageIndonesian: "Sumber",
+	LanguageEnglish:    "Sources",
+}
+
+type FeedFormat string
+
+const (
+	FeedRSS  FeedFormat = "rss"
+	FeedAtom FeedFormat = "atom"
+	FeedJSON FeedFormat = "json"
+)
+
+var feedFormats = []FeedFormat{FeedRSS, FeedAtom, FeedJSON}
+
+// feedFileNames maps a feed format to the file name used both by the HTTP
+// endpoints and by the static export.
+var feedFileNames = map[FeedFormat]string{
+	FeedRSS:  "rss.xml",
+	FeedAtom: "atom.xml",
+	FeedJSON: "feed.json",
+}
+
+var feedContentTypes = map[FeedFormat]string{
+	FeedRSS:  "application/rss+xml; charset=utf-8",
+	FeedAtom: "application/atom+xml; charset=utf-8",
+	FeedJSON: "application/feed+json; charset=utf-8",
+}
+
+func feedFormatFromFileName(name string) (FeedFormat, bool) {
+	return lo.FindKey(feedFileNames, name)
+}
+
+func siteURL() string {
+	url := os.Getenv("SITE_URL")
+	if url == "" {
+		url = "http://localhost:8080"
+	}
+	return strings.TrimSuffix(url, "/")
+}
+
+// editionLanguage maps an empty language to the Indonesian original.
+func editionLanguage(language string) string {
+	return lo.CoalesceOrEmpty(language, LanguageIndonesian)
+}
+
+// editionURL is the root of a language edition; the other editions live
+// below a language prefix such as /en.
+func editionURL(language string) string {
+	language = editionLanguage(language)
+	if language == LanguageIndonesian {
+		return siteURL()
+	}
+	return siteURL() + "/" + language
+}
+
+func articleURL(article Article) string {
+	return fmt.Sprintf("%s/articles/%d", editionURL(article.Language), article.ID)
+}
+
+func feedURL(language string, category string, format FeedFormat) string {
+	if category == "" {
+		return fmt.Sprintf("%s/feeds/%s", editionURL(language), feedFileNames[format])
+	}
+	return fmt.Sprintf("%s/feeds/%s/%s", editionURL(language), category, feedFileNames[format])
+}
+
+func feedTitleFor(language string, category string) string {
+	if category == "" {
+		return feedTitle
+	}
+	return feedTitle + " - " + categoryLabel(language, category)
+}
+
+var paragraphSeparator = regexp.MustCompile(`\n\s*\n`)
+
+// longContentHTML converts the plain-text long_content produced by the
+// summarizer into HTML paragraphs.
+func longContentHTML(content string) string {
+	var sb strings.Builder
+	for _, paragraph := range paragraphSeparator.Split(content, -1) {
+		paragraph = strings.TrimSpace(paragraph)
+		if paragraph == "" {
+			continue
+		}
+		sb.WriteString("<p>")
+		sb.WriteString(strings.ReplaceAll(html.EscapeString(paragraph), "\n", "<br>"))
+		sb.WriteString("</p>")
+	}
+	return sb.String()
+}
+
+// articleContentHTML renders the long content followed by the list of
+// original source links.
+func articleContentHTML(article Article) string {
+	var sb strings.Builder
+	sb.WriteString(longContentHTML(article.LongContent))
+	if len(article.Links) > 0 {
+		fmt.Fprintf(&sb, "<p>%s:</p><ul>", sourcesLabels[editionLanguage(article.Language)])
+		for i, link := range article.Links {
+			label := link
+			if i < len(article.Sources) {
+				label = article.Sources[i]
+			}
+			fmt.Fprintf(&sb, `<li><a href="%s">%s</a></li>`, html.EscapeString(link), html.EscapeString(label))
+		}
+		sb.WriteString("</ul>")
+	}
+	return sb.String()
+}
+
+// RenderFeed renders the articles of one language edition, empty being
+// the Indonesian original.
+func RenderFeed(format FeedFormat, language string, category string, articles []Article) ([]byte, error) {
+	language = editionLanguage(language)
+	switch format {
+	case FeedRSS:
+		return renderRSS(language, category, articles)
+	case FeedAtom:
+		return renderAtom(language, category, articles)
+	case FeedJSON:
+		return renderJSONFeed(language, category, articles)
+	default:
+		return nil, fmt.Errorf("unknown feed format %q", format)
+	}
+}
+
+func feedUpdatedAt(articles []Article) time.Time {
+	if len(articles) == 0 {
+		return time.Now()
+	}
+	return articles[0].CreatedAt
+}
+
+type rssFeed struct {
+	XMLName          xml.Name   `xml:"rss"`
+	Version          string     `xml:"version,attr"`
+	ContentNamespace string     `xml:"xmlns:content,attr"`
+	Channel          rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	AtomLink      atomLink  `xml:"http://www.w3.org/2005/Atom link"`
+	Description   string    `xml:"description"`
+	Language      string    `xml:"language"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title          string        `xml:"title"`
+	Link           string        `xml:"link"`
+	GUID           rssGUID       `xml:"guid"`
+	Description    string        `xml:"description"`
+	ContentEncoded rssCDATA      `xml:"content:encoded"`
+	Category       string        `xml:"category,omitempty"`
+	PubDate        string        `xml:"pubDate"`
+	Source         []rssSource   `xml:"source,omitempty"`
+	Enclosure      *rssEnclosure `xml:"enclosure,omitempty"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssCDATA struct {
+	Value string `xml:",cdata"`
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int    `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+type rssSource struct {
+	URL   string `xml:"url,attr"`
+	Value string `xml:",chardata"`
+}
+
+func renderRSS(language string, category string, articles []Article) ([]byte, error) {
+	feed := rssFeed{
+		Version:          "2.0",
+		ContentNamespace: "http://purl.org/rss/1.0/modules/content/",
+		Channel: rssChannel{
+			Title:         feedTitleFor(language, category),
+			Link:          editionURL(language),
+			AtomLink:      atomLink{Href: feedURL(language, category, FeedRSS), Rel: "self", Type: "application/rss+xml"},
+			Description:   feedDescriptions[language],
+			Language:      language,
+			LastBuildDate: feedUpdatedAt(articles).Format(time.RFC1123Z),
+		},
+	}
+	for _, article := range articles {
+		item := rssItem{
+			Title:          article.Title,
+			Link:           articleURL(article),
+			GUID:           rssGUID{IsPermaLink: true, Value: articleURL(article)},
+			Description:    article.Excerpt,
+			ContentEncoded: rssCDATA{Value: articleContentHTML(article)},
+			Category:       article.Category,
+			PubDate:        article.CreatedAt.Format(time.RFC1123Z),
+		}
+		for i, link := range article.Links {
+			source := rssSource{URL: link, Value: link}
+			if i < len(article.Sources) {
+				source.Value = article.Sources[i]
+			}
+			item.Source = append(item.Source, source)
+		}
+		// RSS requires the length and type of enclosures, images stored
+		// without them are left out
+		if url, contentType, length := article.Image.Enclosure(); url != "" && contentType != "" && length > 0 {
+			item.Enclosure = &rssEnclosure{URL: url, Length: length, Type: contentType}
+		}
+		feed.Channel.Items = append(feed.Channel.Items, item)
+	}
+
+	output, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling rss feed: %v", err)
+	}
+	return append([]byte(xml.Header), output...), nil
+}
+
+type atomFeed struct {
+	XMLName  xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Lang     string      `xml:"xml:lang,attr"`
+	ID       string      `xml:"id"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle"`
+	Updated  string      `xml:"updated"`
+	Links    []atomLink  `xml:"link"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href   string `xml:"href,attr"`
+	Rel    string `xml:"rel,attr,omitempty"`
+	Type   string `xml:"type,attr,omitempty"`
+	Title  string `xml:"title,attr,omitempty"`
+	Length int    `xml:"length,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID        string       `xml:"id"`
+	Title     string       `xml:"title"`
+	Updated   string       `xml:"updated"`
+	Published string       `xml:"published"`
+	Links     []atomLink   `xml:"link"`
+	Category  atomCategory `xml:"category"`
+	Summary   atomText     `xml:"summary"`
+	Content   atomText     `xml:"content"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+func renderAtom(language string, category string, articles []Article) ([]byte, error) {
+	feed := atomFeed{
+		Lang:     language,
+		ID:       feedURL(language, category, FeedAtom),
+		Title:    feedTitleFor(language, category),
+		Subtitle: feedDescriptions[language],
+		Updated:  feedUpdatedAt(articles).Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: feedURL(language, category, FeedAtom), Rel: "self", Type: "application/atom+xml"},
+			{Href: editionURL(language), Rel: "alternate", Type: "text/html"},
+		},
+	}
+	for _, article := range articles {
+		entry := atomEntry{
+			ID:        articleURL(article),
+			Title:     article.Title,
+			Updated:   article.CreatedAt.Format(time.RFC3339),
+			Published: article.CreatedAt.Format(time.RFC3339),
+			Links:     []atomLink{{Href: articleURL(article), Rel: "alternate", Type: "text/html"}},
+			Category:  atomCategory{Term: article.Category},
+			Summary:   atomText{Type: "text", Value: article.Excerpt},
+			Content:   atomText{Type: "html", Value: articleContentHTML(article)},
+		}
+		for i, link := range article.Links {
+			related := atomLink{Href: link, Rel: "related", Type: "text/html"}
+			if i < len(article.Sources) {
+				related.Title = article.Sources[i]
+			}
+			entry.Links = append(entry.Links, related)
+		}
+		if url, contentType, length := article.Image.Enclosure(); url != "" {
+			entry.Links = append(entry.Links, atomLink{Href: url, Rel: "enclosure", Type: contentType, Length: length})
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	output, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling atom feed: %v", err)
+	}
+	return append([]byte(xml.Header), output...), nil
+}
+
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url"`
+	FeedURL     string         `json:"feed_url"`
+	Description string         `json:"description"`
+	Language    string         `json:"language"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	ID            string           `json:"id"`
+	URL           string           `json:"url"`
+	Title         string           `json:"title"`
+	Summary       string           `json:"summary"`
+	ContentHTML   string           `json:"content_html"`
+	Image         string           `json:"image,omitempty"`
+	DatePublished string           `json:"date_published"`
+	Tags          []string         `json:"tags,omitempty"`
+	Sources       []jsonFeedSource `json:"_sources,omitempty"`
+}
+
+// jsonFeedSource is a custom JSON Feed extension listing the original news
+// the story was summarized from.
+type jsonFeedSource struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+func renderJSONFeed(language string, category string, articles []Article) ([]byte, error) {
+	feed := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       feedTitleFor(language, category),
+		HomePageURL: editionURL(language),
+		FeedURL:     feedURL(language, category, FeedJSON),
+		Description: feedDescriptions[language],
+		Language:    language,
+		Items:       []jsonFeedItem{},
+	}
+	for _, article := range articles {
+		item := jsonFeedItem{
+			ID:            strconv.FormatInt(article.ID, 10),
+			URL:           articleURL(article),
+			Title:         article.Title,
+			Summary:       article.Excerpt,
+			ContentHTML:   articleContentHTML(article),
+			Image:         article.Image.URL(),
+			DatePublished: article.CreatedAt.Format(time.RFC3339),
+			Tags:          []string{article.Category},
+		}
+		for i, link := range article.Links {
+			source := jsonFeedSource{URL: link}
+			if i < len(article.Sources) {
+				source.Name = article.Sources[i]
+			}
+			item.Sources = append(item.Sources, source)
+		}
+		feed.Items = append(feed.Items, item)
+	}
+
+	output, err := json.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling json feed: %v", err)
+	}
+	return output, nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ExportFeeds writes every feed format of a language edition, both for all
+// articles and for each category, below dir using the same layout as the
+// /feeds endpoints.
+func ExportFeeds(db *sql.DB, dir string, language string, limit int) error {
+	for _, category := range append([]string{""}, categories()...) {
+		articles, err := ListArticles(db, ArticleQuery{Category: category, Limit: limit, Language: language})
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(dir, category)
+		if err := os.MkdirAll(target, 0o755); err != nil {
+			return fmt.Errorf("error creating directory %s: %v", target, err)
+		}
+		for _, format := range feedFormats {
+			output, err := RenderFeed(format, language, category, articles)
+			if err != nil {
+				return err
+			}
+			path := filepath.Join(target, feedFileNames[format])
+			if err := os.WriteFile(path, output, 0o644); err != nil {
+				return fmt.Errorf("error writing %s: %v", path, err)
+			}
+			logger.Debug("Feed written", "path", path, "articles", len(articles))
+		}
+	}
+	return nil
+}
+
+func runFeeds(db *sql.DB, args []string) error {
+	flags := flag.NewFlagSet("feeds", flag.ExitOnError)
+	out := flags.String("out", "./public/feeds", "directory to write the feeds to")
+	limit := flags.Int("limit", defaultFeedLimit, "maximum number of articles per feed")
+	lang := flags.String("lang", "", "edition language of the feeds, e.g. en (default Indonesian)")
+	flags.Parse(args)
+
+	if !validEdition(*lang) {
+		return fmt.Errorf("unsupported lang %q", *lang)
+	}
+	return ExportFeeds(db, *out, *lang, *limit)
+}
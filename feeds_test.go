@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderRSS(t *testing.T) {
+	variants := map[string]string{"large": "https://img.example.com/1/large.jpg", "medium": "https://img.example.com/1/medium.jpg"}
+	tests := []struct {
+		name      string
+		image     *ArticleImage
+		enclosure string
+	}{
+		{"no image", nil, ""},
+		{"stored variant", &ArticleImage{Variants: variants, Type: "image/jpeg", Sizes: map[string]int{"large": 81234, "medium": 30120}},
+			`<enclosure url="https://img.example.com/1/large.jpg" length="81234" type="image/jpeg"></enclosure>`},
+		{"length unknown", &ArticleImage{Variants: variants}, ""},
+	}
+	for _, test := range tests {
+		article := Article{ID: 1, Title: "Banjir", Excerpt: "Banjir di Bekasi.", Category: "national", Image: test.image, CreatedAt: time.Date(2025, 5, 12, 7, 0, 0, 0, jakarta)}
+		output, err := RenderFeed(FeedRSS, "", "", []Article{article})
+		if err != nil {
+			t.Fatal(err)
+		}
+		rss := string(output)
+		if test.enclosure == "" && strings.Contains(rss, "<enclosure") {
+			t.Errorf("%s: unexpected enclosure in\n%s", test.name, rss)
+		}
+		if test.enclosure != "" && !strings.Contains(rss, test.enclosure) {
+			t.Errorf("%s: no %s in\n%s", test.name, test.enclosure, rss)
+		}
+
+		// the self link must resolve to the Atom namespace
+		var feed struct {
+			Channel struct {
+				Self struct {
+					Href string `xml:"href,attr"`
+					Rel  string `xml:"rel,attr"`
+				} `xml:"http://www.w3.org/2005/Atom link"`
+			} `xml:"channel"`
+		}
+		if err := xml.Unmarshal(output, &feed); err != nil {
+			t.Fatal(err)
+		}
+		if feed.Channel.Self.Rel != "self" || feed.Channel.Self.Href != feedURL("", "", FeedRSS) {
+			t.Errorf("%s: atom self link %+v", test.name, feed.Channel.Self)
+		}
+	}
+}
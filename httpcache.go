@@ -0,0 +1,393 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cacheStatusHeader is added to responses served by the HTTP cache.
+const cacheStatusHeader = "X-Crawler-Cache"
+
+const (
+	CacheMiss        = "miss"
+	CacheHit         = "hit"
+	CacheRevalidated = "revalidated"
+)
+
+type cacheEntry struct {
+	URL      string      `json:"url"`
+	Status   int         `json:"status"`
+	Header   http.Header `json:"header"`
+	StoredAt time.Time   `json:"stored_at"`
+	// Committed is set on deferred entries, which are only written once
+	// the run that fetched them succeeded.
+	Committed bool `json:"committed,omitempty"`
+}
+
+type pendingEntry struct {
+	entry *cacheEntry
+	body  []byte
+}
+
+type deferredCacheKey struct{}
+
+// withDeferredCache marks the requests made with ctx so their responses are
+// kept in memory until the cache is committed. Feeds use it so they are not
+// seen as unchanged by the next run when this one fails before their items
+// are saved.
+func withDeferredCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, deferredCacheKey{}, true)
+}
+
+func deferredCache(ctx context.Context) bool {
+	deferred, _ := ctx.Value(deferredCacheKey{}).(bool)
+	return deferred
+}
+
+type cacheControl struct {
+	noStore bool
+	noCache bool
+	maxAge  time.Duration
+	hasAge  bool
+}
+
+func parseCacheControl(header http.Header) cacheControl {
+	var cc cacheControl
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(strings.ToLower(directive)), "=")
+		switch name {
+		case "no-store":
+			cc.noStore = true
+		case "no-cache":
+			cc.noCache = true
+		case "max-age":
+			if seconds, err := strconv.Atoi(strings.Trim(value, `"`)); err == nil {
+				cc.maxAge = time.Duration(seconds) * time.Second
+				cc.hasAge = true
+			}
+		}
+	}
+	return cc
+}
+
+// fresh reports whether the entry can be served without contacting the
+// origin, based on Cache-Control max-age or Expires.
+func (e *cacheEntry) fresh(now time.Time) bool {
+	cc := parseCacheControl(e.Header)
+	if cc.noCache {
+		return false
+	}
+	if cc.hasAge {
+		return now.Before(e.StoredAt.Add(cc.maxAge))
+	}
+	if expires, err := http.ParseTime(e.Header.Get("Expires")); err == nil {
+		return now.Before(expires)
+	}
+	return false
+}
+
+// HTTPCache is an on-disk HTTP cache implemented as an http.RoundTripper.
+// Fresh responses are served from disk, stale ones are revalidated with
+// If-None-Match/If-Modified-Since. It also remembers the cached_at value of
+// each feed so unchanged feeds can be skipped. Deferred responses and new
+// cached_at values are only saved by Commit.
+type HTTPCache struct {
+	dir  string
+	base http.RoundTripper
+
+	mu           sync.Mutex
+	feeds        map[string]time.Time
+	pendingFeeds map[string]time.Time
+	pending      map[string]pendingEntry
+}
+
+// defaultHTTPCacheMaxAge is how long entries are kept since they were last
+// written. Feeds are rewritten by every run, article pages are mostly
+// fetched once.
+const defaultHTTPCacheMaxAge = 7 * 24 * time.Hour
+
+// NewHTTPCache opens the cache in dir, first removing the entries not
+// written for maxAge so the cache does not grow with every article page.
+// A zero maxAge keeps every entry.
+func NewHTTPCache(dir string, base http.RoundTripper, maxAge time.Duration) (*HTTPCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating cache directory %s: %v", dir, err)
+	}
+	cache := &HTTPCache{
+		dir:          dir,
+		base:         base,
+		feeds:        map[string]time.Time{},
+		pendingFeeds: map[string]time.Time{},
+		pending:      map[string]pendingEntry{},
+	}
+	data, err := os.ReadFile(cache.feedStatePath())
+	if err == nil {
+		json.Unmarshal(data, &cache.feeds)
+	}
+	if maxAge > 0 {
+		cache.sweep(time.Now().Add(-maxAge))
+	}
+	return cache, nil
+}
+
+// sweep removes the entries and leftover temporary files last written
+// before cutoff.
+func (c *HTTPCache) sweep(cutoff time.Time) {
+	files, err := os.ReadDir(c.dir)
+	if err != nil {
+		logger.Error("Error reading cache directory", "dir", c.dir, "error", err)
+		return
+	}
+	removed := 0
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || name == filepath.Base(c.feedStatePath()) {
+			continue
+		}
+		info, err := file.Info()
+		if err != nil || !info.ModTime().Before(cutoff) {
+			continue
+		}
+		if key, ok := strings.CutSuffix(name, ".json"); ok {
+			// the body goes with its entry, as a revalidation only rewrites
+			// the entry
+			os.Remove(filepath.Join(c.dir, key+".body"))
+		} else if !strings.HasPrefix(name, ".tmp-") {
+			continue
+		}
+		if err := os.Remove(filepath.Join(c.dir, name)); err == nil {
+			removed++
+		}
+	}
+	if removed > 0 {
+		logger.Debug("Expired HTTP cache entries removed", "dir", c.dir, "entries", removed)
+	}
+}
+
+func (c *HTTPCache) key(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *HTTPCache) feedStatePath() string {
+	return filepath.Join(c.dir, "feeds.json")
+}
+
+func (c *HTTPCache) load(url string) (*cacheEntry, []byte) {
+	key := c.key(url)
+	meta, err := os.ReadFile(filepath.Join(c.dir, key+".json"))
+	if err != nil {
+		return nil, nil
+	}
+	body, err := os.ReadFile(filepath.Join(c.dir, key+".body"))
+	if err != nil {
+		return nil, nil
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(meta, &entry); err != nil || entry.URL != url {
+		return nil, nil
+	}
+	return &entry, body
+}
+
+// writeAtomic writes to a temporary file first so concurrent readers never
+// see partial content.
+func writeAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (c *HTTPCache) store(entry *cacheEntry, body []byte) {
+	key := c.key(entry.URL)
+	meta, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	if body != nil {
+		if err := writeAtomic(filepath.Join(c.dir, key+".body"), body); err != nil {
+			logger.Error("Error writing cache entry", "url", entry.URL, "error", err)
+			return
+		}
+	}
+	if err := writeAtomic(filepath.Join(c.dir, key+".json"), meta); err != nil {
+		logger.Error("Error writing cache entry", "url", entry.URL, "error", err)
+	}
+}
+
+func (c *HTTPCache) response(req *http.Request, entry *cacheEntry, body []byte, status string) *http.Response {
+	header := entry.Header.Clone()
+	header.Set(cacheStatusHeader, status)
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", entry.Status, http.StatusText(entry.Status)),
+		StatusCode:    entry.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+func (c *HTTPCache) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet || parseCacheControl(req.Header).noStore {
+		return c.base.RoundTrip(req)
+	}
+
+	url := req.URL.String()
+	deferred := deferredCache(req.Context())
+	entry, body := c.load(url)
+	// a deferred entry that was never committed belongs to a run that did
+	// not finish, so its content may not have been processed
+	if deferred && entry != nil && !entry.Committed {
+		entry, body = nil, nil
+	}
+	save := c.store
+	if deferred {
+		save = c.hold
+	}
+	if entry != nil && entry.fresh(time.Now()) {
+		return c.response(req, entry, body, CacheHit), nil
+	}
+
+	if entry != nil {
+		req = req.Clone(req.Context())
+		if etag := entry.Header.Get("ETag"); etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if lastModified := entry.Header.Get("Last-Modified"); lastModified != "" {
+			req.Header.Set("If-Modified-Since", lastModified)
+		}
+	}
+
+	resp, err := c.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified && entry != nil {
+		resp.Body.Close()
+		// merge the refreshed headers, e.g. a new max-age
+		for name, values := range resp.Header {
+			entry.Header[name] = values
+		}
+		entry.StoredAt = time.Now()
+		save(entry, nil)
+		return c.response(req, entry, body, CacheRevalidated), nil
+	}
+
+	cc := parseCacheControl(resp.Header)
+	cacheable := resp.StatusCode == http.StatusOK && !cc.noStore &&
+		(cc.hasAge || resp.Header.Get("ETag") != "" || resp.Header.Get("Last-Modified") != "" || resp.Header.Get("Expires") != "")
+	if !cacheable {
+		resp.Header.Set(cacheStatusHeader, CacheMiss)
+		return resp, nil
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	entry = &cacheEntry{URL: url, Status: resp.StatusCode, Header: resp.Header.Clone(), StoredAt: time.Now()}
+	save(entry, data)
+
+	resp.Header.Set(cacheStatusHeader, CacheMiss)
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	resp.ContentLength = int64(len(data))
+	return resp, nil
+}
+
+// hold keeps an entry in memory until Commit.
+func (c *HTTPCache) hold(entry *cacheEntry, body []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pending[entry.URL] = pendingEntry{entry: entry, body: body}
+}
+
+// FeedUnchanged reports whether the feed's cached_at equals the value saved
+// by the last committed run, and remembers the new value until Commit.
+func (c *HTTPCache) FeedUnchanged(url string, cachedAt time.Time) bool {
+	if cachedAt.IsZero() {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	previous, ok := c.feeds[url]
+	if ok && previous.Equal(cachedAt) {
+		return true
+	}
+	c.pendingFeeds[url] = cachedAt
+	return false
+}
+
+// Forget drops what was kept for url since the last Commit, for feeds whose
+// items were not all processed.
+func (c *HTTPCache) Forget(url string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.pending, url)
+	delete(c.pendingFeeds, url)
+}
+
+// Discard drops everything kept since the last Commit.
+func (c *HTTPCache) Discard() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pending = map[string]pendingEntry{}
+	c.pendingFeeds = map[string]time.Time{}
+}
+
+// Commit saves the deferred entries and the feeds' cached_at values, once
+// the run that fetched them succeeded.
+func (c *HTTPCache) Commit() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, pending := range c.pending {
+		pending.entry.Committed = true
+		// a revalidated entry keeps the body already on disk
+		c.store(pending.entry, pending.body)
+	}
+	c.pending = map[string]pendingEntry{}
+
+	if len(c.pendingFeeds) == 0 {
+		return
+	}
+	for url, cachedAt := range c.pendingFeeds {
+		c.feeds[url] = cachedAt
+	}
+	c.pendingFeeds = map[string]time.Time{}
+	data, err := json.Marshal(c.feeds)
+	if err == nil {
+		err = writeAtomic(c.feedStatePath(), data)
+	}
+	if err != nil {
+		logger.Error("Error saving feed state", "error", err)
+	}
+}
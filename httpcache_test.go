@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseCacheControl(t *testing.T) {
+	tests := []struct {
+		header string
+		want   cacheControl
+	}{
+		{"", cacheControl{}},
+		{"no-store", cacheControl{noStore: true}},
+		{"No-Cache, max-age=0", cacheControl{noCache: true, hasAge: true}},
+		{"public, max-age=300", cacheControl{maxAge: 300 * time.Second, hasAge: true}},
+		{`max-age="60"`, cacheControl{maxAge: time.Minute, hasAge: true}},
+		{"max-age=soon", cacheControl{}},
+	}
+	for _, test := range tests {
+		header := http.Header{}
+		header.Set("Cache-Control", test.header)
+		if got := parseCacheControl(header); got != test.want {
+			t.Errorf("parseCacheControl(%q) = %+v, want %+v", test.header, got, test.want)
+		}
+	}
+}
+
+func TestCacheEntryFresh(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name   string
+		header http.Header
+		stored time.Time
+		want   bool
+	}{
+		{"max-age", http.Header{"Cache-Control": {"max-age=60"}}, now.Add(-30 * time.Second), true},
+		{"expired max-age", http.Header{"Cache-Control": {"max-age=60"}}, now.Add(-2 * time.Minute), false},
+		{"no-cache", http.Header{"Cache-Control": {"no-cache, max-age=60"}}, now, false},
+		{"expires", http.Header{"Expires": {now.Add(time.Hour).UTC().Format(http.TimeFormat)}}, now, true},
+		{"validators only", http.Header{"Etag": {`"v1"`}}, now, false},
+	}
+	for _, test := range tests {
+		entry := &cacheEntry{Header: test.header, StoredAt: test.stored}
+		if got := entry.fresh(now); got != test.want {
+			t.Errorf("%s: fresh = %t, want %t", test.name, got, test.want)
+		}
+	}
+}
+
+func TestHTTPCacheDeferred(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Cache-Control", "max-age=3600")
+		io.WriteString(w, "feed")
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	get := func(cache *HTTPCache, ctx context.Context) string {
+		t.Helper()
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+		resp, err := cache.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.Header.Get(cacheStatusHeader)
+	}
+
+	cache, err := NewHTTPCache(dir, http.DefaultTransport, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := withDeferredCache(context.Background())
+	if status := get(cache, ctx); status != CacheMiss {
+		t.Fatalf("first fetch: status %q", status)
+	}
+	// a failed run leaves nothing behind
+	cache.FeedUnchanged(server.URL, time.Unix(100, 0))
+	cache.Discard()
+
+	cache, _ = NewHTTPCache(dir, http.DefaultTransport, 0)
+	if status := get(cache, ctx); status != CacheMiss {
+		t.Errorf("after a discarded run: status %q, want %q", status, CacheMiss)
+	}
+	if cache.FeedUnchanged(server.URL, time.Unix(100, 0)) {
+		t.Errorf("feed unchanged after a discarded run")
+	}
+	cache.Commit()
+
+	cache, _ = NewHTTPCache(dir, http.DefaultTransport, 0)
+	if status := get(cache, ctx); status != CacheHit {
+		t.Errorf("after a committed run: status %q, want %q", status, CacheHit)
+	}
+	if !cache.FeedUnchanged(server.URL, time.Unix(100, 0)) {
+		t.Errorf("feed changed after a committed run")
+	}
+	if requests != 2 {
+		t.Errorf("origin requested %d times, want 2", requests)
+	}
+}
+
+func TestHTTPCacheSweep(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := NewHTTPCache(dir, http.DefaultTransport, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-48 * time.Hour)
+	for _, url := range []string{"https://news.detik.com/old", "https://news.detik.com/new"} {
+		cache.store(&cacheEntry{URL: url, Status: http.StatusOK, Header: http.Header{}, StoredAt: time.Now()}, []byte("page"))
+	}
+	cache.FeedUnchanged("https://feeds.example.com/detik", time.Unix(100, 0))
+	cache.Commit()
+	// the body of the recent entry is older, as after a revalidation
+	for _, name := range []string{cache.key("https://news.detik.com/old") + ".json", cache.key("https://news.detik.com/old") + ".body", cache.key("https://news.detik.com/new") + ".body", "feeds.json"} {
+		if err := os.Chtimes(filepath.Join(dir, name), old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cache, err = NewHTTPCache(dir, http.DefaultTransport, 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry, _ := cache.load("https://news.detik.com/old"); entry != nil {
+		t.Errorf("expired entry kept")
+	}
+	if _, err := os.Stat(filepath.Join(dir, cache.key("https://news.detik.com/old")+".body")); !os.IsNotExist(err) {
+		t.Errorf("body of the expired entry kept: %v", err)
+	}
+	if entry, body := cache.load("https://news.detik.com/new"); entry == nil || string(body) != "page" {
+		t.Errorf("recent entry removed")
+	}
+	if !cache.FeedUnchanged("https://feeds.example.com/detik", time.Unix(100, 0)) {
+		t.Errorf("feed state removed")
+	}
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	_ "image/gif"
+	_ "image/png"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/samber/lo"
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+	"resty.dev/v3"
+)
+
+const (
+	defaultImageMinWidth  = 400
+	defaultImageMinHeight = 225
+	defaultImageMaxBytes  = 10 << 20
+	// defaultImageMaxPixels bounds the memory taken by decoding, as a small
+	// file can declare huge dimensions.
+	defaultImageMaxPixels = 40_000_000
+)
+
+// imageVariants are the widths lead images are resized to. Images are never
+// upscaled, so a variant can be smaller than its nominal width.
+var imageVariants = []struct {
+	Name  string
+	Width int
+}{
+	{"large", 1200},
+	{"medium", 640},
+	{"thumbnail", 320},
+}
+
+// imageVariantType is the media type every variant is encoded as.
+const imageVariantType = "image/jpeg"
+
+// ArticleImage is the lead image chosen for a story.
+type ArticleImage struct {
+	Source   string            `json:"source"`
+	Width    int               `json:"width"`
+	Height   int               `json:"height"`
+	Variants map[string]string `json:"variants"`
+	// Type and Sizes, the byte length of each variant, are unset for
+	// images stored before they were recorded.
+	Type  string         `json:"type,omitempty"`
+	Sizes map[string]int `json:"sizes,omitempty"`
+}
+
+// URL returns the largest stored variant.
+func (i *ArticleImage) URL() string {
+	url, _ := i.largest()
+	return url
+}
+
+// Enclosure returns the largest stored variant with its media type and
+// byte length, which are empty and 0 when they were not recorded.
+func (i *ArticleImage) Enclosure() (url string, contentType string, length int) {
+	url, name := i.largest()
+	if url == "" {
+		return "", "", 0
+	}
+	return url, i.Type, i.Sizes[name]
+}
+
+func (i *ArticleImage) largest() (url string, name string) {
+	if i == nil {
+		return "", ""
+	}
+	for _, variant := range imageVariants {
+		if url, ok := i.Variants[variant.Name]; ok {
+			return url, variant.Name
+		}
+	}
+	return "", ""
+}
+
+// ImageStore saves resized images and returns their public URL.
+type ImageStore interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) (string, error)
+}
+
+// LocalImageStore writes images below a directory which is served under
+// baseURL.
+type LocalImageStore struct {
+	dir     string
+	baseURL string
+}
+
+func (s *LocalImageStore) Put(ctx context.Context, key string, data []byte, contentType string) (string, error) {
+	path := filepath.Join(s.dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", fmt.Errorf("error creating image directory: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return "", fmt.Errorf("error writing image %s: %v", key, err)
+	}
+	return s.baseURL + "/" + key, nil
+}
+
+// S3ImageStore uploads images to an S3 compatible bucket such as MinIO.
+type S3ImageStore struct {
+	client  *minio.Client
+	bucket  string
+	baseURL string
+}
+
+func (s *S3ImageStore) Put(ctx context.Context, key string, data []byte, contentType string) (string, error) {
+	_, err := s.client.PutObject(ctx, s.bucket, key, bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{
+		ContentType:  contentType,
+		CacheControl: "public, max-age=31536000, immutable",
+	})
+	if err != nil {
+		return "", fmt.Errorf("error uploading image %s: %v", key, err)
+	}
+	return s.baseURL + "/" + key, nil
+}
+
+// localImageDir is where the local image store writes and the server reads.
+func localImageDir() string {
+	if dir := os.Getenv("IMAGE_DIR"); dir != "" {
+		return dir
+	}
+	return "./public/images"
+}
+
+// NewImageStore returns the store selected by IMAGE_STORE, "local" by
+// default or "s3".
+func NewImageStore(ctx context.Context) (ImageStore, error) {
+	switch store := os.Getenv("IMAGE_STORE"); store {
+	case "", "local":
+		baseURL := os.Getenv("IMAGE_BASE_URL")
+		if baseURL == "" {
+			baseURL = siteURL() + "/images"
+		}
+		return &LocalImageStore{dir: localImageDir(), baseURL: strings.TrimSuffix(baseURL, "/")}, nil
+	case "s3":
+		endpoint := os.Getenv("S3_ENDPOINT")
+		bucket := os.Getenv("S3_BUCKET")
+		if endpoint == "" || bucket == "" {
+			return nil, errors.New("S3_ENDPOINT and S3_BUCKET are required for the s3 image store")
+		}
+		useSSL := envBool("S3_USE_SSL", true)
+		client, err := minio.New(endpoint, &minio.Options{
+			Creds:  credentials.NewStaticV4(os.Getenv("S3_ACCESS_KEY"), os.Getenv("S3_SECRET_KEY"), ""),
+			Secure: useSSL,
+			Region: os.Getenv("S3_REGION"),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error creating s3 client: %v", err)
+		}
+		exists, err := client.BucketExists(ctx, bucket)
+		if err != nil {
+			return nil, fmt.Errorf("error checking bucket %s: %v", bucket, err)
+		}
+		if !exists {
+			return nil, fmt.Errorf("bucket %s does not exist", bucket)
+		}
+
+		baseURL := os.Getenv("S3_PUBLIC_URL")
+		if baseURL == "" {
+			baseURL = fmt.Sprintf("%s://%s/%s", lo.Ternary(useSSL, "https", "http"), endpoint, bucket)
+		}
+		return &S3ImageStore{client: client, bucket: bucket, baseURL: strings.TrimSuffix(baseURL, "/")}, nil
+	default:
+		return nil, fmt.Errorf("unknown image store %q", store)
+	}
+}
+
+// articleImages returns the absolute URLs of the images inside the given
+// containers of an article page, skipping inline data URIs.
+func articleImages(doc *goquery.Document, pageURL string, selector string) []string {
+	base, _ := url.Parse(pageURL)
+	var images []string
+	doc.Find(selector).Find("img").Each(func(i int, s *goquery.Selection) {
+		// lazy loaded images keep the real source in a data attribute
+		src := lo.CoalesceOrEmpty(s.AttrOr("data-src", ""), s.AttrOr("data-original", ""), s.AttrOr("src", ""))
+		src = strings.TrimSpace(src)
+		if src == "" || strings.HasPrefix(src, "data:") {
+			return
+		}
+		if base != nil {
+			if ref, err := base.Parse(src); err == nil {
+				src = ref.String()
+			}
+		}
+		images = append(images, src)
+	})
+	return lo.Uniq(images)
+}
+
+// leadImageCandidates lists the images of the articles a story was written
+// from, feed thumbnails first, then og:image and then in-article images.
+func leadImageCandidates(articles []CrawlerResult) []string {
+	var candidates []string
+	for _, article := range articles {
+		candidates = append(candidates, article.Thumbnail)
+	}
+	for _, article := range articles {
+		candidates = append(candidates, article.ImageURL)
+	}
+	for _, article := range articles {
+		candidates = append(candidates, article.Images...)
+	}
+	return lo.Uniq(lo.Filter(candidates, func(candidate string, _ int) bool {
+		return strings.HasPrefix(candidate, "http://") || strings.HasPrefix(candidate, "https://")
+	}))
+}
+
+// SelectLeadImage stores the first candidate that is a large enough image,
+// resized into every variant. It returns nil when no candidate is usable.
+func SelectLeadImage(ctx context.Context, client *resty.Client, store ImageStore, articleID int64, candidates []string) (_ *ArticleImage, err error) {
+	ctx, span := startSpan(ctx, "images.lead", attribute.Int64("article.id", articleID), attribute.Int("candidates", len(candidates)))
+	defer func() { endSpan(span, err) }()
+
+	for _, candidate := range candidates {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		img, err := downloadImage(ctx, client, candidate)
+		if err != nil {
+			logger.Debug("Skipping lead image candidate", "url", candidate, "error", err)
+			continue
+		}
+
+		bounds := img.Bounds()
+		stored := &ArticleImage{Source: candidate, Width: bounds.Dx(), Height: bounds.Dy(), Variants: map[string]string{}, Type: imageVariantType, Sizes: map[string]int{}}
+		for _, variant := range imageVariants {
+			data, err := encodeVariant(img, variant.Width)
+			if err != nil {
+				return nil, err
+			}
+			url, err := store.Put(ctx, fmt.Sprintf("%d/%s.jpg", articleID, variant.Name), data, imageVariantType)
+			if err != nil {
+				return nil, err
+			}
+			stored.Variants[variant.Name] = url
+			stored.Sizes[variant.Name] = len(data)
+		}
+		return stored, nil
+	}
+	return nil, nil
+}
+
+// downloadImage fetches and decodes an image, rejecting responses that are
+// not images, larger than IMAGE_MAX_BYTES or IMAGE_MAX_PIXELS, or smaller
+// than IMAGE_MIN_WIDTH x IMAGE_MIN_HEIGHT.
+func downloadImage(ctx context.Context, client *resty.Client, url string) (image.Image, error) {
+	resp, err := client.R().
+		SetContext(ctx).
+		SetHeader("Accept", "image/*").
+		Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.IsError() {
+		return nil, errors.New(resp.Status())
+	}
+	if contentType := resp.Header().Get("Content-Type"); contentType != "" && !strings.HasPrefix(contentType, "image/") {
+		return nil, fmt.Errorf("unexpected content type %q", contentType)
+	}
+
+	maxBytes := envInt("IMAGE_MAX_BYTES", defaultImageMaxBytes)
+	data, err := io.ReadAll(io.LimitReader(resp.Body, int64(maxBytes)+1))
+	if err != nil {
+		return nil, fmt.Errorf("error reading image: %v", err)
+	}
+	if len(data) > maxBytes {
+		return nil, fmt.Errorf("image larger than %d bytes", maxBytes)
+	}
+
+	// check the dimensions before decoding the whole image
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding image config: %v", err)
+	}
+	minWidth := envInt("IMAGE_MIN_WIDTH", defaultImageMinWidth)
+	minHeight := envInt("IMAGE_MIN_HEIGHT", defaultImageMinHeight)
+	if cfg.Width < minWidth || cfg.Height < minHeight {
+		return nil, fmt.Errorf("%s image too small: %dx%d", format, cfg.Width, cfg.Height)
+	}
+	if maxPixels := envInt("IMAGE_MAX_PIXELS", defaultImageMaxPixels); int64(cfg.Width)*int64(cfg.Height) > int64(maxPixels) {
+		return nil, fmt.Errorf("%s image too large: %dx%d", format, cfg.Width, cfg.Height)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding image: %v", err)
+	}
+	return img, nil
+}
+
+// encodeVariant scales img down to width, keeping its aspect ratio, and
+// encodes it as JPEG on a white background.
+func encodeVariant(img image.Image, width int) ([]byte, error) {
+	bounds := img.Bounds()
+	width = min(width, bounds.Dx())
+	height := max(bounds.Dy()*width/bounds.Dx(), 1)
+	scaled := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(scaled, scaled.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(scaled, scaled.Bounds(), img, bounds, draw.Over, nil)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, scaled, &jpeg.Options{Quality: 85}); err != nil {
+		return nil, fmt.Errorf("error encoding image: %v", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"resty.dev/v3"
+)
+
+// pngWithSize encodes a small PNG whose header declares width x height.
+func pngWithSize(t *testing.T, width, height uint32) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 500, 300))); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	// the IHDR chunk follows the 8 byte signature: length, type, data, crc
+	binary.BigEndian.PutUint32(data[16:20], width)
+	binary.BigEndian.PutUint32(data[20:24], height)
+	binary.BigEndian.PutUint32(data[29:33], crc32.ChecksumIEEE(data[12:29]))
+	return data
+}
+
+func TestDownloadImage(t *testing.T) {
+	tests := []struct {
+		name   string
+		width  uint32
+		height uint32
+		err    string
+	}{
+		{"valid", 500, 300, ""},
+		{"too small", 300, 200, "too small"},
+		{"declares too many pixels", 50000, 50000, "too large"},
+	}
+	for _, test := range tests {
+		data := pngWithSize(t, test.width, test.height)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(data)
+		}))
+		client := resty.New()
+
+		_, err := downloadImage(t.Context(), client, server.URL)
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%s: %v", test.name, err)
+		case test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)):
+			t.Errorf("%s: error %v, want %q", test.name, err, test.err)
+		}
+		client.Close()
+		server.Close()
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+const (
+	LanguageIndonesian   = "id"
+	LanguageEnglish      = "en"
+	LanguageUndetermined = "und"
+)
+
+const (
+	// minLanguageWords is the fewest words a text needs to be detected.
+	minLanguageWords = 20
+	// minLanguageScore is the share of stopwords the winning language
+	// needs.
+	minLanguageScore = 0.05
+)
+
+// languageStopwords are frequent function words that are rare in the other
+// languages, so their share of a text tells the languages apart.
+var languageStopwords = map[string]map[string]bool{
+	LanguageIndonesian: wordSet(`yang dan di ke dari ini itu dengan untuk pada adalah dalam tidak akan juga oleh
+		sebagai karena atau ada mereka kami kita saya telah sudah bisa dapat para tersebut lebih hingga namun
+		setelah menurut bahwa serta saat masih belum bagi secara agar jika kata bukan sejak antara terhadap`),
+	LanguageEnglish: wordSet(`the and of to in is that for on with as was were are by at from it this be has
+		have had said will would not but an which their they its been who after than more also or about into
+		he she we there when what while over could should our`),
+}
+
+func wordSet(words string) map[string]bool {
+	set := map[string]bool{}
+	for _, word := range strings.Fields(words) {
+		set[word] = true
+	}
+	return set
+}
+
+// DetectLanguage returns the language of text, LanguageIndonesian or
+// LanguageEnglish, and the share of its words that are stopwords of that
+// language. Texts too short or without a clear winner are
+// LanguageUndetermined.
+func DetectLanguage(text string) (string, float64) {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	if len(words) < minLanguageWords {
+		return LanguageUndetermined, 0
+	}
+
+	hits := map[string]int{}
+	for _, word := range words {
+		for language, stopwords := range languageStopwords {
+			if stopwords[word] {
+				hits[language]++
+			}
+		}
+	}
+
+	best, second := LanguageUndetermined, 0
+	for language, count := range hits {
+		if count > hits[best] {
+			best, second = language, hits[best]
+		} else if count > second {
+			second = count
+		}
+	}
+	score := float64(hits[best]) / float64(len(words))
+	// the winner must clearly beat the runner-up, mixed texts stay
+	// undetermined
+	if score < minLanguageScore || hits[best] < 2*second {
+		return LanguageUndetermined, score
+	}
+	return best, score
+}
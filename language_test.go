@@ -0,0 +1,42 @@
+package main
+
+import "testing"
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{
+			"indonesian",
+			"Ratusan rumah di Bekasi terendam banjir setelah hujan deras yang turun sejak sore. Menurut warga, air mulai masuk ke rumah pada malam hari dan belum surut hingga pagi ini.",
+			LanguageIndonesian,
+		},
+		{
+			"english",
+			"Hundreds of homes in Bekasi were flooded after heavy rain that fell since the afternoon. According to residents, the water entered their houses at night and had not receded by this morning.",
+			LanguageEnglish,
+		},
+		{
+			"too short",
+			"Banjir rendam ratusan rumah di Bekasi.",
+			LanguageUndetermined,
+		},
+		{
+			"mixed",
+			"The flood in Bekasi was reported by residents and the city. Banjir di Bekasi dilaporkan oleh warga dan pemerintah kota yang sudah turun ke lokasi.",
+			LanguageUndetermined,
+		},
+		{
+			"no stopwords",
+			"Banjir Bekasi hujan deras warga rumah air malam pagi sore kota jalan sungai tanggul pompa posko pengungsi bantuan logistik relawan petugas",
+			LanguageUndetermined,
+		},
+	}
+	for _, test := range tests {
+		if got, score := DetectLanguage(test.text); got != test.want {
+			t.Errorf("%s: detected %q (%.2f), want %q", test.name, got, score, test.want)
+		}
+	}
+}
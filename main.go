@@ -1,16 +1,19 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"log"
 	"log/slog"
 	"os"
-	"strings"
-	"sync"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/godruoyi/go-snowflake"
 	"github.com/joho/godotenv"
-	"resty.dev/v3"
+	"github.com/samber/lo"
 )
 
 var targets = []string{
@@ -23,6 +26,15 @@ var targets = []string{
 	"https://news-api-id.abidf.com/rss/kumparan/news",
 	"https://news-api-id.abidf.com/rss/kumparan/bisnis",
 	"https://news-api-id.abidf.com/rss/cnbc/market",
+	"https://news-api-id.abidf.com/rss/cnbc/tech",
+	"https://news-api-id.abidf.com/rss/cnbc/lifestyle",
+	"https://news-api-id.abidf.com/rss/cnn/teknologi",
+	"https://news-api-id.abidf.com/rss/cnn/olahraga",
+	"https://news-api-id.abidf.com/rss/cnn/gaya-hidup",
+	"https://news-api-id.abidf.com/rss/detik/news",
+	"https://news-api-id.abidf.com/rss/tempo/nasional",
+	"https://news-api-id.abidf.com/rss/antara/terkini",
+	"https://news-api-id.abidf.com/rss/tribun/news",
 }
 
 var logger = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
@@ -35,83 +47,286 @@ func main() {
 		log.Fatal("Error loading .env file")
 	}
 
+	if err := LoadConfig(); err != nil {
+		log.Fatal(err)
+	}
+
 	db, cleanup, err := InitDB()
 	if err != nil {
 		log.Fatal("Error initializing database")
 	}
 	defer cleanup()
-	var client = resty.New().
-		SetRetryCount(3).
-		SetRetryWaitTime(2 * time.Second).
-		SetRetryMaxWaitTime(10 * time.Second)
-	var wg sync.WaitGroup
-
-	var rawArticles []CrawlerResult
-	for _, target := range targets {
-		wg.Add(1)
-		go func(target string) {
-			defer wg.Done()
-			articles, err := StartCrawler(target, client)
+
+	// cancel in-flight work on SIGINT/SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing, err := InitTracing(ctx)
+	if err != nil {
+		log.Fatal("Error initializing tracing")
+	}
+
+	// the first argument selects the command, crawling is the default
+	command := "crawl"
+	args := os.Args[1:]
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+
+	switch command {
+	case "crawl":
+		err = runCrawl(ctx, db)
+		pushMetrics()
+	case "daemon":
+		err = runDaemon(ctx, db, args)
+	case "serve":
+		err = runServe(ctx, db, args)
+	case "feeds":
+		err = runFeeds(db, args)
+	case "export":
+		err = runExport(db, args)
+	case "digest":
+		err = runDigest(ctx, db, args)
+	case "runs":
+		err = runRuns(db, args)
+	case "webhooks":
+		err = runWebhooks(ctx, db, args)
+	case "prompts":
+		err = runPrompts(db, args)
+	case "recategorize":
+		err = runRecategorize(db, args)
+	default:
+		logger.Error("Unknown command", "command", command)
+		shutdownTracing()
+		cleanup()
+		os.Exit(2)
+	}
+	if err != nil {
+		logger.Error("Command failed", "command", command, "error", err)
+		shutdownTracing()
+		cleanup()
+		os.Exit(1)
+	}
+
+	logger.Info("Done")
+	shutdownTracing()
+	cleanup()
+	// exit when done
+	os.Exit(0)
+}
+
+// runCrawl runs the whole pipeline once. The run is bounded by RUN_TIMEOUT
+// and each stage by its own timeout; articles summarized before a deadline
+// is hit are still saved.
+func runCrawl(ctx context.Context, db *sql.DB) (err error) {
+	start := time.Now()
+	// webhooks of stories saved after the run deadline are still delivered,
+	// only shutting down stops them
+	webhookCtx := ctx
+	ctx, cancel := context.WithTimeout(ctx, envDuration("RUN_TIMEOUT", 30*time.Minute))
+	defer cancel()
+
+	report := NewRunReport(int64(snowflake.ID()))
+	ctx, span := startSpan(withRunReport(ctx, report), "crawl.run")
+	defer func() {
+		endSpan(span, err)
+		report.Finish(err)
+		if err := SaveRunReport(db, report); err != nil {
+			logger.Error("Error saving run report", "run_id", report.ID, "error", err)
+		}
+	}()
+	logger.Info("Starting crawl run", "run_id", report.ID)
+	if err := RecordPromptVersions(ctx, db); err != nil {
+		report.RecordError(err)
+		return err
+	}
+
+	webhooks := NewWebhookDispatcher(webhookCtx, db)
+	defer webhooks.Wait()
+
+	client, cache, err := NewCrawlerClient(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if cache != nil {
+		// feeds only count as seen once their stories are saved
+		defer func() {
 			if err != nil {
-				logger.Error("Error fetching URL", "url", target, "error", err)
+				cache.Discard()
 				return
 			}
-			rawArticles = append(rawArticles, *articles...)
-		}(target)
+			cache.Commit()
+		}()
 	}
-
-	wg.Wait()
+	images, err := NewImageStore(ctx)
+	if err != nil {
+		return err
+	}
+	engine := NewCrawlEngine(client, cache, envInt("CRAWL_CONCURRENCY", defaultCrawlConcurrency), envInt("CRAWL_PER_HOST_CONCURRENCY", defaultPerHostConcurrency))
+	crawlCtx, cancelCrawl := context.WithTimeout(ctx, envDuration("CRAWL_TIMEOUT", 10*time.Minute))
+	defer cancelCrawl()
+	rawArticles := engine.Run(crawlCtx, targets)
+	engine.Close()
 	logger.Debug("Raw articles", "articles", rawArticles)
-	groups, err := Grouper(rawArticles)
+	if err := SaveRawArticles(ctx, db, report.ID, rawArticles); err != nil {
+		logger.Error("Error saving raw articles", "error", err)
+		report.RecordError(err)
+	}
+	if err := CheckExtractorHealth(ctx, db, report, webhooks); err != nil {
+		logger.Error("Error checking extractor health", "error", err)
+	}
+	groupCtx, cancelGroup := context.WithTimeout(ctx, envDuration("GROUP_TIMEOUT", 2*time.Minute))
+	defer cancelGroup()
+	groups, err := Grouper(groupCtx, rawArticles)
 	if err != nil {
-		logger.Error("Error grouping articles", "error", err)
-		os.Exit(1)
+		report.RecordError(err)
+		return err
 	}
+	report.Grouped(len(groups.Groups))
 
 	// sleep for 3 second
 	logger.Debug("Sleeping for 3 second")
-	time.Sleep(3 * time.Second)
+	select {
+	case <-time.After(3 * time.Second):
+	case <-ctx.Done():
+	}
+
+	// saving must not be interrupted by the run deadline so that stories
+	// already paid for are kept
+	storeCtx := context.WithoutCancel(ctx)
 
 	// summarize each group
-	for _, group := range groups.Groups {
+	for i, group := range groups.Groups {
+		if errors.Is(ctx.Err(), context.Canceled) {
+			return ctx.Err()
+		}
+		if ctx.Err() != nil {
+			// the run still succeeds with the stories saved so far; the feeds
+			// of the skipped news are fetched again by the next run
+			skipped := groups.Groups[i:]
+			logger.Warn("Run deadline reached, skipping remaining groups", "remaining", len(skipped))
+			report.GroupsSkipped(lo.Map(skipped, func(group []CrawlerResult, _ int) []string {
+				return lo.Map(group, func(item CrawlerResult, _ int) string {
+					return item.Link
+				})
+			}), "skipped: run deadline reached")
+			if cache != nil {
+				for _, group := range skipped {
+					for _, item := range group {
+						cache.Forget(item.Target)
+					}
+				}
+			}
+			break
+		}
+
 		var articles []Summarizer
 		for _, g := range group {
 			articles = append(articles, Summarizer{
-				Source:  g.Source,
-				Title:   g.Title,
-				Content: g.Content,
-				Link:    g.Link,
+				Source:      g.Source,
+				Title:       g.Title,
+				Content:     g.Content,
+				Link:        g.Link,
+				Author:      g.Author,
+				Section:     g.Section,
+				Keywords:    g.Keywords,
+				PublishedAt: g.PublishedAt,
+				ModifiedAt:  g.ModifiedAt,
+				PageType:    lo.Ternary(g.PageType == PageArticle, "", g.PageType),
+				Language:    lo.Ternary(g.Language == LanguageIndonesian, "", g.Language),
 			})
 		}
-		summarizerResponse, err := Summarize(articles)
+		summarizerResponse, issues, err := SummarizeChecked(ctx, articles)
 		if err != nil {
 			logger.Error("Error summarizing articles", "error", err)
+			report.SummarizationFailed(lo.Map(articles, func(article Summarizer, _ int) string {
+				return article.Link
+			}), err)
 			continue
 		}
 
 		// for each summary, save to db
-		createdAt := time.Now().Format("2006-01-02 15:04:05")
-		for _, article := range summarizerResponse.Articles {
-			id := int64(snowflake.ID())
+		createdAt := time.Now()
+		var storedArticles []Article
+		for i, article := range summarizerResponse.Articles {
+			// links the model made up are never published
+			article.Sources = lo.Filter(article.Sources, func(source string, _ int) bool {
+				return lo.ContainsBy(articles, func(input Summarizer) bool {
+					return input.Link == source
+				})
+			})
+
 			// merge sources
 			var sources []string
 			for _, source := range article.Sources {
 				sources = append(sources, normalizeSource(source))
 			}
 
-			_, err := db.Exec(`
-			INSERT INTO articles (id, title, excerpt, long_content, sources, links, category, ai_model, created_at)
-			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
-		`, id, article.Title, article.Excerpt, article.LongContent, strings.Join(sources, ","), strings.Join(article.Sources, ","), article.Category, summarizerResponse.AiModel, createdAt)
+			stored := Article{
+				ID:            int64(snowflake.ID()),
+				Title:         article.Title,
+				Excerpt:       article.Excerpt,
+				LongContent:   article.LongContent,
+				Sources:       sources,
+				Links:         article.Sources,
+				Category:      article.Category,
+				Subcategory:   lo.Ternary(validSubcategory(article.Category, article.Subcategory), article.Subcategory, ""),
+				AiModel:       summarizerResponse.AiModel,
+				PromptVersion: summarizerResponse.PromptVersion,
+				QualityFlags:  issues[i],
+				Language:      LanguageIndonesian,
+				CreatedAt:     createdAt,
+			}
+
+			// pick the lead image from the articles the story was written from
+			used := lo.Filter(group, func(item CrawlerResult, _ int) bool {
+				return lo.Contains(article.Sources, item.Link)
+			})
+			image, err := SelectLeadImage(ctx, client, images, stored.ID, leadImageCandidates(lo.Ternary(len(used) > 0, used, group)))
 			if err != nil {
+				logger.Warn("Error selecting lead image", "id", stored.ID, "error", err)
+			}
+			stored.Image = image
+
+			if err := InsertArticle(storeCtx, db, &stored); err != nil {
 				logger.Error("Error inserting article", "error", err)
-				return
+				report.RecordError(err)
+				return err
+			}
+			logger.Debug("Article saved", "id", stored.ID)
+			if len(stored.QualityFlags) > 0 {
+				logger.Warn("Article saved with quality flags", "id", stored.ID, "flags", stored.QualityFlags)
+			}
+			articlesInserted.WithLabelValues(stored.Category).Inc()
+			report.ArticleInserted(stored.ID, stored.AiModel)
+			webhooks.Dispatch(EventArticleCreated, stored)
+			storedArticles = append(storedArticles, stored)
+		}
+
+		// the other editions are a best effort, the Indonesian stories are
+		// already published
+		for _, language := range editionLanguages() {
+			translateCtx, cancelTranslate := context.WithTimeout(ctx, envDuration("SUMMARIZE_TIMEOUT", 2*time.Minute))
+			translations, err := TranslateArticles(translateCtx, storedArticles, language)
+			cancelTranslate()
+			if err != nil {
+				logger.Error("Error translating articles", "language", language, "error", err)
+				report.RecordError(err)
+				continue
+			}
+			for _, translation := range translations {
+				if err := InsertArticleTranslation(storeCtx, db, &translation); err != nil {
+					logger.Error("Error inserting article translation", "error", err)
+					report.RecordError(err)
+					return err
+				}
+				logger.Debug("Article translation saved", "id", translation.ArticleID, "language", language)
 			}
-			logger.Debug("Article saved", "id", id)
 		}
 	}
 
-	logger.Info("Done")
-	// exit when done
-	os.Exit(0)
+	runDuration.Set(time.Since(start).Seconds())
+	runLastSuccess.SetToCurrentTime()
+	return nil
 }
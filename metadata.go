@@ -0,0 +1,259 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/samber/lo"
+)
+
+// ArticleMetadata is the structured data publishers embed in article pages.
+type ArticleMetadata struct {
+	Author      string    `json:"author,omitempty"`
+	Section     string    `json:"section,omitempty"`
+	Keywords    []string  `json:"keywords,omitempty"`
+	ImageURL    string    `json:"image_url,omitempty"`
+	PublishedAt time.Time `json:"published_at,omitzero"`
+	ModifiedAt  time.Time `json:"modified_at,omitzero"`
+}
+
+// merge fills the fields of m that are still empty from other.
+func (m *ArticleMetadata) merge(other ArticleMetadata) {
+	m.Author = lo.CoalesceOrEmpty(m.Author, other.Author)
+	m.Section = lo.CoalesceOrEmpty(m.Section, other.Section)
+	m.ImageURL = lo.CoalesceOrEmpty(m.ImageURL, other.ImageURL)
+	if len(m.Keywords) == 0 {
+		m.Keywords = other.Keywords
+	}
+	if m.PublishedAt.IsZero() {
+		m.PublishedAt = other.PublishedAt
+	}
+	if m.ModifiedAt.IsZero() {
+		m.ModifiedAt = other.ModifiedAt
+	}
+}
+
+// ExtractMetadata reads JSON-LD NewsArticle data, OpenGraph and plain meta
+// tags from doc, in that order of precedence.
+func ExtractMetadata(doc *goquery.Document) ArticleMetadata {
+	metadata := jsonLDMetadata(doc)
+	metadata.merge(openGraphMetadata(doc))
+	metadata.merge(metaTagMetadata(doc))
+	return metadata
+}
+
+var articleTypes = []string{"NewsArticle", "Article", "ReportageNewsArticle", "AnalysisNewsArticle", "OpinionNewsArticle", "BlogPosting", "LiveBlogPosting"}
+
+func jsonLDMetadata(doc *goquery.Document) ArticleMetadata {
+	var metadata ArticleMetadata
+	doc.Find(`script[type="application/ld+json"]`).EachWithBreak(func(i int, s *goquery.Selection) bool {
+		var data any
+		if err := json.Unmarshal([]byte(s.Text()), &data); err != nil {
+			return true
+		}
+		article := findJSONLDArticle(data)
+		if article == nil {
+			return true
+		}
+
+		metadata.Author = strings.Join(jsonLDNames(article["author"]), ", ")
+		metadata.Section = strings.Join(jsonLDStrings(article["articleSection"]), ", ")
+		metadata.Keywords = splitKeywords(jsonLDStrings(article["keywords"]))
+		if images := jsonLDURLs(article["image"]); len(images) > 0 {
+			metadata.ImageURL = images[0]
+		}
+		metadata.PublishedAt = parseMetaTime(jsonLDString(article["datePublished"]))
+		metadata.ModifiedAt = parseMetaTime(jsonLDString(article["dateModified"]))
+		return false
+	})
+	return metadata
+}
+
+// findJSONLDArticle walks arrays and @graph containers looking for an
+// object whose @type is an article type.
+func findJSONLDArticle(data any) map[string]any {
+	switch value := data.(type) {
+	case []any:
+		for _, item := range value {
+			if article := findJSONLDArticle(item); article != nil {
+				return article
+			}
+		}
+	case map[string]any:
+		for _, kind := range jsonLDStrings(value["@type"]) {
+			if lo.Contains(articleTypes, kind) {
+				return value
+			}
+		}
+		if graph, ok := value["@graph"]; ok {
+			return findJSONLDArticle(graph)
+		}
+	}
+	return nil
+}
+
+// jsonLDObjects returns every object of the JSON-LD blocks of doc, with
+// arrays and @graph containers flattened.
+func jsonLDObjects(doc *goquery.Document) []map[string]any {
+	var objects []map[string]any
+	var walk func(data any)
+	walk = func(data any) {
+		switch value := data.(type) {
+		case []any:
+			for _, item := range value {
+				walk(item)
+			}
+		case map[string]any:
+			objects = append(objects, value)
+			if graph, ok := value["@graph"]; ok {
+				walk(graph)
+			}
+		}
+	}
+	doc.Find(`script[type="application/ld+json"]`).Each(func(i int, s *goquery.Selection) {
+		var data any
+		if err := json.Unmarshal([]byte(s.Text()), &data); err == nil {
+			walk(data)
+		}
+	})
+	return objects
+}
+
+func jsonLDString(value any) string {
+	text, _ := value.(string)
+	return strings.TrimSpace(text)
+}
+
+func jsonLDStrings(value any) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{strings.TrimSpace(v)}
+	case []any:
+		var values []string
+		for _, item := range v {
+			if text := jsonLDString(item); text != "" {
+				values = append(values, text)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// jsonLDNames reads Person/Organization references, which may be plain
+// strings, objects with a name, or arrays of either.
+func jsonLDNames(value any) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{strings.TrimSpace(v)}
+	case map[string]any:
+		if name := jsonLDString(v["name"]); name != "" {
+			return []string{name}
+		}
+	case []any:
+		var names []string
+		for _, item := range v {
+			names = append(names, jsonLDNames(item)...)
+		}
+		return names
+	}
+	return nil
+}
+
+// jsonLDURLs reads ImageObject references, which may be plain strings,
+// objects with a url, or arrays of either.
+func jsonLDURLs(value any) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{strings.TrimSpace(v)}
+	case map[string]any:
+		if url := jsonLDString(v["url"]); url != "" {
+			return []string{url}
+		}
+	case []any:
+		var urls []string
+		for _, item := range v {
+			urls = append(urls, jsonLDURLs(item)...)
+		}
+		return urls
+	}
+	return nil
+}
+
+func metaContent(doc *goquery.Document, selectors ...string) string {
+	for _, selector := range selectors {
+		if content, ok := doc.Find(selector).First().Attr("content"); ok && strings.TrimSpace(content) != "" {
+			return strings.TrimSpace(content)
+		}
+	}
+	return ""
+}
+
+func openGraphMetadata(doc *goquery.Document) ArticleMetadata {
+	var tags []string
+	doc.Find(`meta[property="article:tag"]`).Each(func(i int, s *goquery.Selection) {
+		if content, ok := s.Attr("content"); ok {
+			tags = append(tags, content)
+		}
+	})
+	return ArticleMetadata{
+		Author:      metaContent(doc, `meta[property="article:author"]`),
+		Section:     metaContent(doc, `meta[property="article:section"]`),
+		Keywords:    splitKeywords(tags),
+		ImageURL:    metaContent(doc, `meta[property="og:image:secure_url"]`, `meta[property="og:image"]`),
+		PublishedAt: parseMetaTime(metaContent(doc, `meta[property="article:published_time"]`)),
+		ModifiedAt:  parseMetaTime(metaContent(doc, `meta[property="article:modified_time"]`, `meta[property="og:updated_time"]`)),
+	}
+}
+
+func metaTagMetadata(doc *goquery.Document) ArticleMetadata {
+	return ArticleMetadata{
+		Author:      metaContent(doc, `meta[name="author"]`, `meta[name="content_author"]`, `meta[name="dtk:author"]`),
+		Section:     metaContent(doc, `meta[name="content_category"]`, `meta[name="section"]`),
+		Keywords:    splitKeywords([]string{metaContent(doc, `meta[name="news_keywords"]`, `meta[name="keywords"]`, `meta[name="content_tag"]`)}),
+		ImageURL:    metaContent(doc, `meta[name="twitter:image"]`, `meta[name="thumbnailUrl"]`),
+		PublishedAt: parseMetaTime(metaContent(doc, `meta[name="content_PublishedDate"]`, `meta[name="publishdate"]`, `meta[itemprop="datePublished"]`)),
+		ModifiedAt:  parseMetaTime(metaContent(doc, `meta[itemprop="dateModified"]`)),
+	}
+}
+
+// splitKeywords splits comma separated keyword lists and removes blanks and
+// duplicates.
+func splitKeywords(values []string) []string {
+	var keywords []string
+	for _, value := range values {
+		for _, keyword := range strings.Split(value, ",") {
+			if keyword = strings.TrimSpace(keyword); keyword != "" {
+				keywords = append(keywords, keyword)
+			}
+		}
+	}
+	return lo.Uniq(keywords)
+}
+
+var metaTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006/01/02 15:04:05",
+	"2006-01-02",
+}
+
+// jakarta is used for timestamps without a zone, which Indonesian
+// publishers emit in WIB.
+var jakarta = time.FixedZone("WIB", 7*60*60)
+
+func parseMetaTime(value string) time.Time {
+	if value == "" {
+		return time.Time{}
+	}
+	for _, layout := range metaTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, jakarta); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
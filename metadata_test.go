@@ -0,0 +1,65 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestExtractMetadata(t *testing.T) {
+	tests := []struct {
+		name string
+		head string
+		want ArticleMetadata
+	}{
+		{
+			"news article",
+			`<script type="application/ld+json">{"@type":"NewsArticle","author":{"@type":"Person","name":"Rina"},"articleSection":"Nasional","keywords":"banjir, bekasi","image":{"@type":"ImageObject","url":"https://img.example.com/a.jpg"},"datePublished":"2025-05-12T07:00:00+07:00","dateModified":"2025-05-12 08:30:00"}</script>`,
+			ArticleMetadata{Author: "Rina", Section: "Nasional", Keywords: []string{"banjir", "bekasi"}, ImageURL: "https://img.example.com/a.jpg", PublishedAt: time.Date(2025, 5, 12, 7, 0, 0, 0, jakarta), ModifiedAt: time.Date(2025, 5, 12, 8, 30, 0, 0, jakarta)},
+		},
+		{
+			"graph with several authors",
+			`<script type="application/ld+json">{"@context":"https://schema.org","@graph":[{"@type":"WebPage"},{"@type":["Article","ReportageNewsArticle"],"author":[{"name":"Rina"},"Budi"],"keywords":["banjir","banjir","bekasi"],"image":["https://img.example.com/a.jpg","https://img.example.com/b.jpg"]}]}</script>`,
+			ArticleMetadata{Author: "Rina, Budi", Keywords: []string{"banjir", "bekasi"}, ImageURL: "https://img.example.com/a.jpg"},
+		},
+		{
+			"array of objects",
+			`<script type="application/ld+json">[{"@type":"Organization","name":"Detik"},{"@type":"BlogPosting","author":"Budi"}]</script>`,
+			ArticleMetadata{Author: "Budi"},
+		},
+		{
+			"invalid block is skipped",
+			`<script type="application/ld+json">{"@type":</script><script type="application/ld+json">{"@type":"NewsArticle","articleSection":"Ekonomi"}</script>`,
+			ArticleMetadata{Section: "Ekonomi"},
+		},
+		{
+			"meta tags fill the gaps",
+			`<script type="application/ld+json">{"@type":"NewsArticle","author":"Rina"}</script>
+			<meta property="article:author" content="Ignored">
+			<meta property="article:section" content="Nasional">
+			<meta property="og:image" content="https://img.example.com/og.jpg">
+			<meta name="keywords" content="pilkada, kpu">
+			<meta name="publishdate" content="2025/05/12 09:00:00">`,
+			ArticleMetadata{Author: "Rina", Section: "Nasional", Keywords: []string{"pilkada", "kpu"}, ImageURL: "https://img.example.com/og.jpg", PublishedAt: time.Date(2025, 5, 12, 9, 0, 0, 0, jakarta)},
+		},
+		{
+			"no article type",
+			`<script type="application/ld+json">{"@type":"WebSite","author":"Detik"}</script>`,
+			ArticleMetadata{},
+		},
+	}
+	for _, test := range tests {
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader("<html><head>" + test.head + "</head><body></body></html>"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := ExtractMetadata(doc)
+		if got.Author != test.want.Author || got.Section != test.want.Section || got.ImageURL != test.want.ImageURL ||
+			strings.Join(got.Keywords, "|") != strings.Join(test.want.Keywords, "|") ||
+			!got.PublishedAt.Equal(test.want.PublishedAt) || !got.ModifiedAt.Equal(test.want.ModifiedAt) {
+			t.Errorf("%s: got %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
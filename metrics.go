@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/push"
+	"google.golang.org/genai"
+	"resty.dev/v3"
+)
+
+const metricsNamespace = "ngopibentar"
+
+var metricsRegistry = prometheus.NewRegistry()
+
+var (
+	feedFetchDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "feed_fetch_duration_seconds",
+		Help:      "Time spent fetching a feed target.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"target", "status"})
+
+	articlesExtracted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "articles_extracted_total",
+		Help:      "Articles whose content was extracted successfully.",
+	}, []string{"publisher"})
+
+	articlesDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "articles_dropped_total",
+		Help:      "Articles dropped during extraction, by reason.",
+	}, []string{"publisher", "reason"})
+
+	llmDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "llm_request_duration_seconds",
+		Help:      "Latency of LLM requests by operation.",
+		Buckets:   []float64{1, 2.5, 5, 10, 20, 30, 60, 120},
+	}, []string{"operation", "status"})
+
+	llmFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "llm_failures_total",
+		Help:      "Failed LLM operations.",
+	}, []string{"operation"})
+
+	llmTokens = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "llm_tokens_total",
+		Help:      "Tokens consumed by LLM requests.",
+	}, []string{"operation", "model", "type"})
+
+	articlesInserted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "articles_inserted_total",
+		Help:      "Generated articles inserted into the database.",
+	}, []string{"category"})
+
+	summaryQualityIssues = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "summary_quality_issues_total",
+		Help:      "Summarized stories failing a quality check, by check.",
+	}, []string{"check"})
+
+	runDuration = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "run_duration_seconds",
+		Help:      "Duration of the last crawl run.",
+	})
+
+	runLastSuccess = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "run_last_success_timestamp_seconds",
+		Help:      "Unix time of the last successful crawl run.",
+	})
+)
+
+func init() {
+	metricsRegistry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		feedFetchDuration,
+		articlesExtracted,
+		articlesDropped,
+		llmDuration,
+		llmFailures,
+		llmTokens,
+		articlesInserted,
+		summaryQualityIssues,
+		runDuration,
+		runLastSuccess,
+	)
+}
+
+func observeFeedFetch(target string, start time.Time, response *resty.Response, err error) {
+	status := "error"
+	if err == nil && response != nil {
+		status = strconv.Itoa(response.StatusCode())
+	}
+	feedFetchDuration.WithLabelValues(target, status).Observe(time.Since(start).Seconds())
+}
+
+// observeLLM records latency, failures and token usage of a GenerateContent
+// call, adding the tokens to the run report carried by ctx.
+func observeLLM(ctx context.Context, operation string, model string, start time.Time, result *genai.GenerateContentResponse, err error) {
+	status := "ok"
+	if err != nil {
+		status = "error"
+		llmFailures.WithLabelValues(operation).Inc()
+	}
+	llmDuration.WithLabelValues(operation, status).Observe(time.Since(start).Seconds())
+
+	if result == nil || result.UsageMetadata == nil {
+		return
+	}
+	usage := result.UsageMetadata
+	reportFromContext(ctx).AddTokens(usage)
+	llmTokens.WithLabelValues(operation, model, "prompt").Add(float64(usage.PromptTokenCount))
+	llmTokens.WithLabelValues(operation, model, "candidates").Add(float64(usage.CandidatesTokenCount))
+	llmTokens.WithLabelValues(operation, model, "total").Add(float64(usage.TotalTokenCount))
+}
+
+// pushMetrics pushes the registry to a Pushgateway compatible endpoint when
+// PUSHGATEWAY_URL is set. Used by one-shot runs which exit before they can
+// be scraped.
+func pushMetrics() {
+	url := os.Getenv("PUSHGATEWAY_URL")
+	if url == "" {
+		return
+	}
+	err := push.New(url, "ngopibentar_crawler").Gatherer(metricsRegistry).Push()
+	if err != nil {
+		logger.Error("Error pushing metrics", "url", url, "error", err)
+		return
+	}
+	logger.Debug("Metrics pushed", "url", url)
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/samber/lo"
+	"google.golang.org/genai"
+)
+
+var digestMarkdownTemplate = template.Must(template.New("").Funcs(template.FuncMap{
+	"articleURL":    articleURL,
+	"categoryLabel": categoryLabel,
+	"join":          strings.Join,
+	"inc": func(i int) int {
+		return i + 1
+	},
+}).ParseFS(templateFiles, "templates/*.md.tmpl"))
+
+type DigestStory struct {
+	Title   string `json:"title"`
+	Excerpt string `json:"excerpt"`
+}
+
+type DigestIntroResponse struct {
+	Intro string `json:"intro"`
+}
+
+type Digest struct {
+	Category string
+	Date     time.Time
+	Intro    string
+	Articles []Article
+	AiModel  string
+}
+
+// topStories ranks articles by the number of sources they were merged from,
+// then by recency, and returns the first n.
+func topStories(articles []Article, n int) []Article {
+	ranked := append([]Article(nil), articles...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if len(ranked[i].Links) != len(ranked[j].Links) {
+			return len(ranked[i].Links) > len(ranked[j].Links)
+		}
+		return ranked[i].CreatedAt.After(ranked[j].CreatedAt)
+	})
+	if len(ranked) > n {
+		ranked = ranked[:n]
+	}
+	return ranked
+}
+
+func GenerateDigestIntro(ctx context.Context, category string, articles []Article) (*DigestIntroResponse, string, error) {
+	prompt, err := LoadPrompt(PromptDigest, newPromptData())
+	if err != nil {
+		return nil, "", err
+	}
+	client, err := genai.NewClient(ctx, &genai.ClientConfig{
+		APIKey:  os.Getenv("GEMINI_API_KEY"),
+		Backend: genai.BackendGeminiAPI,
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("error creating client: %v", err)
+	}
+
+	var stories []DigestStory
+	for _, article := range articles {
+		stories = append(stories, DigestStory{Title: article.Title, Excerpt: article.Excerpt})
+	}
+	jsonPayload, err := json.Marshal(map[string]any{
+		"category": category,
+		"stories":  stories,
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("error marshaling payload: %v", err)
+	}
+
+	logger.Info("Generating digest intro", "category", category)
+	parts := []*genai.Part{
+		{Text: string(jsonPayload)},
+	}
+	aiModel := "gemini-2.0-flash"
+	start := time.Now()
+	result, err := client.Models.GenerateContent(
+		ctx, aiModel,
+		[]*genai.Content{genai.NewContentFromParts(parts, genai.RoleUser)}, &genai.GenerateContentConfig{
+			SystemInstruction: &genai.Content{
+				Parts: []*genai.Part{
+					{Text: prompt.Text},
+				},
+			},
+			ResponseMIMEType: "application/json",
+			ResponseSchema: &genai.Schema{
+				Type: genai.TypeObject,
+				Properties: map[string]*genai.Schema{
+					"intro": {
+						Type: genai.TypeString,
+					},
+				},
+				Required: []string{"intro"},
+			},
+		})
+	observeLLM(ctx, "digest", aiModel, start, result, err)
+	if err != nil {
+		return nil, "", fmt.Errorf("error generating content: %v", err)
+	}
+
+	if len(result.Candidates) == 0 {
+		return nil, "", fmt.Errorf("no candidates returned from Gemini API")
+	}
+
+	var response DigestIntroResponse
+	logger.Debug("digest intro result", "result", result.Candidates[0].Content.Parts[0].Text)
+	err = json.Unmarshal([]byte(result.Candidates[0].Content.Parts[0].Text), &response)
+	if err != nil {
+		return nil, "", fmt.Errorf("error unmarshaling result: %v", err)
+	}
+
+	return &response, aiModel, nil
+}
+
+func BuildDigest(ctx context.Context, db *sql.DB, category string, now time.Time, top int) (*Digest, error) {
+	articles, err := ListArticles(db, ArticleQuery{Category: category, Since: now.Add(-24 * time.Hour)})
+	if err != nil {
+		return nil, err
+	}
+	if len(articles) == 0 {
+		return nil, nil
+	}
+
+	digest := &Digest{
+		Category: category,
+		Date:     now,
+		Articles: topStories(articles, top),
+	}
+	introCtx, cancelIntro := context.WithTimeout(ctx, envDuration("SUMMARIZE_TIMEOUT", 2*time.Minute))
+	intro, aiModel, err := GenerateDigestIntro(introCtx, category, digest.Articles)
+	cancelIntro()
+	if err != nil {
+		// the digest is still useful without an intro
+		logger.Error("Error generating digest intro", "category", category, "error", err)
+	} else {
+		digest.Intro = intro.Intro
+		digest.AiModel = aiModel
+	}
+	return digest, nil
+}
+
+func (d *Digest) Subject() string {
+	return fmt.Sprintf("Ngopibentar %s: %s", categoryLabel(LanguageIndonesian, d.Category), d.Date.Format("02 Jan 2006"))
+}
+
+func (d *Digest) Render() (markdown []byte, html []byte, err error) {
+	var md bytes.Buffer
+	if err := digestMarkdownTemplate.ExecuteTemplate(&md, "digest.md.tmpl", d); err != nil {
+		return nil, nil, fmt.Errorf("error rendering markdown digest: %v", err)
+	}
+	var body bytes.Buffer
+	if err := siteTemplates.ExecuteTemplate(&body, "digest.html.tmpl", d); err != nil {
+		return nil, nil, fmt.Errorf("error rendering html digest: %v", err)
+	}
+	return md.Bytes(), body.Bytes(), nil
+}
+
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+	To       []string
+}
+
+func smtpConfigFromEnv() SMTPConfig {
+	config := SMTPConfig{
+		Host:     os.Getenv("SMTP_HOST"),
+		Port:     os.Getenv("SMTP_PORT"),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     os.Getenv("DIGEST_FROM"),
+		To:       splitList(os.Getenv("DIGEST_TO")),
+	}
+	if config.Port == "" {
+		config.Port = "25"
+	}
+	return config
+}
+
+// SendDigest sends the digest as a multipart/alternative email containing
+// both the Markdown (as plain text) and the HTML bodies.
+func SendDigest(config SMTPConfig, subject string, markdown []byte, html []byte) error {
+	if config.Host == "" || config.From == "" || len(config.To) == 0 {
+		return fmt.Errorf("SMTP_HOST, DIGEST_FROM and DIGEST_TO must be set to send digests")
+	}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	fmt.Fprintf(&body, "From: %s\r\n", config.From)
+	fmt.Fprintf(&body, "To: %s\r\n", strings.Join(config.To, ", "))
+	// labels and dates may not be ASCII, which headers must be
+	fmt.Fprintf(&body, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&body, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&body, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&body, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", writer.Boundary())
+
+	for _, part := range []struct {
+		contentType string
+		content     []byte
+	}{
+		{"text/plain; charset=utf-8", markdown},
+		{"text/html; charset=utf-8", html},
+	} {
+		w, err := writer.CreatePart(textproto.MIMEHeader{"Content-Type": {part.contentType}})
+		if err != nil {
+			return fmt.Errorf("error creating email part: %v", err)
+		}
+		w.Write(part.content)
+	}
+	writer.Close()
+
+	var auth smtp.Auth
+	if config.Username != "" {
+		auth = smtp.PlainAuth("", config.Username, config.Password, config.Host)
+	}
+	err := smtp.SendMail(config.Host+":"+config.Port, auth, config.From, config.To, body.Bytes())
+	if err != nil {
+		return fmt.Errorf("error sending email: %v", err)
+	}
+	return nil
+}
+
+func runDigest(ctx context.Context, db *sql.DB, args []string) error {
+	flags := flag.NewFlagSet("digest", flag.ExitOnError)
+	out := flags.String("out", "./digests", "directory to write the digests to")
+	top := flags.Int("top", 5, "number of top stories per category")
+	category := flags.String("category", "", "only generate the digest for this category")
+	send := flags.Bool("send", false, "send the digests through the configured SMTP server")
+	flags.Parse(args)
+
+	selected := categories()
+	if *category != "" {
+		if !lo.Contains(selected, *category) {
+			return fmt.Errorf("unknown category %q", *category)
+		}
+		selected = []string{*category}
+	}
+
+	now := time.Now()
+	dir := filepath.Join(*out, now.Format("2006-01-02"))
+	for _, category := range selected {
+		digest, err := BuildDigest(ctx, db, category, now, *top)
+		if err != nil {
+			return err
+		}
+		if digest == nil {
+			logger.Info("No stories for digest", "category", category)
+			continue
+		}
+
+		markdown, html, err := digest.Render()
+		if err != nil {
+			return err
+		}
+		if err := writeFile(filepath.Join(dir, category+".md"), markdown); err != nil {
+			return err
+		}
+		if err := writeFile(filepath.Join(dir, category+".html"), html); err != nil {
+			return err
+		}
+		logger.Info("Digest written", "category", category, "dir", dir, "stories", len(digest.Articles))
+
+		if *send {
+			if err := SendDigest(smtpConfigFromEnv(), digest.Subject(), markdown, html); err != nil {
+				return err
+			}
+			logger.Info("Digest sent", "category", category)
+		}
+	}
+	return nil
+}
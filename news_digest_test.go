@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+	"unicode"
+)
+
+// smtpSink is a local SMTP server accepting a single message.
+type smtpSink struct {
+	listener   net.Listener
+	from       string
+	recipients []string
+	data       chan string
+}
+
+func newSMTPSink(t *testing.T) *smtpSink {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	sink := &smtpSink{listener: listener, data: make(chan string, 1)}
+	go sink.serve()
+	return sink
+}
+
+func (s *smtpSink) serve() {
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	reply := func(line string) {
+		io.WriteString(conn, line+"\r\n")
+	}
+
+	reply("220 localhost ESMTP sink")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(command, "MAIL FROM:"):
+			s.from = strings.Trim(strings.TrimSpace(line)[len("MAIL FROM:"):], "<>")
+			reply("250 OK")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			s.recipients = append(s.recipients, strings.Trim(strings.TrimSpace(line)[len("RCPT TO:"):], "<>"))
+			reply("250 OK")
+		case command == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(line, "."))
+			}
+			s.data <- data.String()
+			reply("250 OK")
+		case command == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func TestSendDigest(t *testing.T) {
+	sink := newSMTPSink(t)
+	host, port, _ := net.SplitHostPort(sink.listener.Addr().String())
+
+	digest := &Digest{
+		Category: "national",
+		Date:     time.Date(2025, 5, 12, 7, 0, 0, 0, jakarta),
+		Intro:    "Banjir dan jadwal pilkada menjadi sorotan hari ini.",
+		Articles: []Article{
+			{ID: 1, Title: "Banjir Rendam Bekasi", Excerpt: "Ratusan rumah terendam.", Category: "national", Links: []string{"https://www.liputan6.com/news/read/1/banjir"}, Sources: []string{"Liputan6"}, CreatedAt: time.Now()},
+			{ID: 2, Title: "KPU Tetapkan Jadwal Pilkada", Excerpt: "Jadwal pilkada ditetapkan.", Category: "national", Links: []string{"https://news.detik.com/berita/d-2/kpu"}, Sources: []string{"Detik"}, CreatedAt: time.Now()},
+		},
+	}
+	markdown, html, err := digest.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := SMTPConfig{Host: host, Port: port, From: "digest@ngopibentar.com", To: []string{"a@example.com", "b@example.com"}}
+	subject := digest.Subject() + " – Edisi Pagi"
+	if err := SendDigest(config, subject, markdown, html); err != nil {
+		t.Fatal(err)
+	}
+
+	var data string
+	select {
+	case data = <-sink.data:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+	}
+	if sink.from != config.From || strings.Join(sink.recipients, ",") != "a@example.com,b@example.com" {
+		t.Errorf("envelope from %q to %q", sink.from, sink.recipients)
+	}
+
+	message, err := mail.ReadMessage(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := message.Header.Get("Subject")
+	if strings.ContainsFunc(header, func(r rune) bool { return r > unicode.MaxASCII }) {
+		t.Errorf("subject header is not encoded: %q", header)
+	}
+	if decoded, err := new(mime.WordDecoder).DecodeHeader(header); err != nil || decoded != "Ngopibentar Nasional: 12 May 2025 – Edisi Pagi" {
+		t.Errorf("subject %q: %v", decoded, err)
+	}
+	mediaType, params, err := mime.ParseMediaType(message.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("content type %q: %v", message.Header.Get("Content-Type"), err)
+	}
+
+	parts := map[string]string{}
+	reader := multipart.NewReader(message.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, _ := io.ReadAll(part)
+		contentType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		parts[contentType] = string(content)
+	}
+	for _, contentType := range []string{"text/plain", "text/html"} {
+		body, ok := parts[contentType]
+		if !ok {
+			t.Errorf("no %s part", contentType)
+			continue
+		}
+		for _, want := range []string{digest.Intro, "Banjir Rendam Bekasi", "KPU Tetapkan Jadwal Pilkada"} {
+			if !strings.Contains(body, want) {
+				t.Errorf("%s part does not contain %q", contentType, want)
+			}
+		}
+	}
+}
+
+func TestSendDigestNeedsConfig(t *testing.T) {
+	if err := SendDigest(SMTPConfig{Host: "localhost"}, "subject", nil, nil); err == nil {
+		t.Errorf("sent without sender and recipients")
+	}
+}
+
+func TestRunDigestUnknownCategory(t *testing.T) {
+	err := runDigest(t.Context(), testDB(t), []string{"-out", t.TempDir(), "-category", "gossip"})
+	if err == nil || !strings.Contains(err.Error(), "gossip") {
+		t.Errorf("error %v for an unknown category", err)
+	}
+}
@@ -5,8 +5,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/samber/lo"
+	"go.opentelemetry.io/otel/attribute"
 	"google.golang.org/genai"
 )
 
@@ -23,8 +25,22 @@ type GrouperResponse struct {
 	Groups [][]CrawlerResult `json:"groups"`
 }
 
-func Grouper(payload []CrawlerResult) (*GrouperResponse, error) {
-	ctx := context.Background()
+func Grouper(ctx context.Context, payload []CrawlerResult) (_ *GrouperResponse, err error) {
+	aiModel := "gemini-2.0-flash"
+	ctx, span := startSpan(ctx, "grouper", attribute.Int("articles", len(payload)))
+	defer func() {
+		if err != nil {
+			err = &LLMError{Operation: "group", Model: aiModel, Err: err}
+		}
+		endSpan(span, err)
+	}()
+
+	prompt, err := LoadPrompt(PromptGrouper, newPromptData())
+	if err != nil {
+		return nil, err
+	}
+	span.SetAttributes(attribute.String("prompt.version", prompt.Version))
+
 	client, err := genai.NewClient(ctx, &genai.ClientConfig{
 		APIKey:  os.Getenv("GEMINI_API_KEY"),
 		Backend: genai.BackendGeminiAPI,
@@ -50,12 +66,13 @@ func Grouper(payload []CrawlerResult) (*GrouperResponse, error) {
 	parts := []*genai.Part{
 		{Text: string(jsonPayload)},
 	}
+	start := time.Now()
 	result, err := client.Models.GenerateContent(
-		ctx, "gemini-2.0-flash",
+		ctx, aiModel,
 		[]*genai.Content{genai.NewContentFromParts(parts, genai.RoleUser)}, &genai.GenerateContentConfig{
 			SystemInstruction: &genai.Content{
 				Parts: []*genai.Part{
-					{Text: systemInstructionGrouper},
+					{Text: prompt.Text},
 				},
 			},
 			ResponseMIMEType: "application/json",
@@ -75,6 +92,7 @@ func Grouper(payload []CrawlerResult) (*GrouperResponse, error) {
 				Required: []string{"groups"},
 			},
 		})
+	observeLLM(ctx, "group", aiModel, start, result, err)
 	if err != nil {
 		return nil, fmt.Errorf("error generating content: %v", err)
 	}
@@ -104,5 +122,6 @@ func Grouper(payload []CrawlerResult) (*GrouperResponse, error) {
 		grouperResponse.Groups = append(grouperResponse.Groups, crawlerResults)
 	}
 
+	span.SetAttributes(attribute.Int("groups", len(grouperResponse.Groups)))
 	return &grouperResponse, nil
 }
@@ -5,15 +5,25 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"time"
 
+	"github.com/samber/lo"
+	"go.opentelemetry.io/otel/attribute"
 	"google.golang.org/genai"
 )
 
 type Summarizer struct {
-	Source  string `json:"source"`
-	Title   string `json:"title"`
-	Content string `json:"content"`
-	Link    string `json:"link"`
+	Source      string    `json:"source"`
+	Title       string    `json:"title"`
+	Content     string    `json:"content"`
+	Link        string    `json:"link"`
+	Author      string    `json:"author,omitempty"`
+	Section     string    `json:"section,omitempty"`
+	Keywords    []string  `json:"keywords,omitempty"`
+	PublishedAt time.Time `json:"published_at,omitzero"`
+	ModifiedAt  time.Time `json:"modified_at,omitzero"`
+	PageType    PageType  `json:"page_type,omitempty"`
+	Language    string    `json:"language,omitempty"`
 }
 
 type AIResponse struct {
@@ -22,25 +32,44 @@ type AIResponse struct {
 	Sources     []string `json:"sources"`
 	Title       string   `json:"title"`
 	Category    string   `json:"category"`
+	Subcategory string   `json:"subcategory,omitempty"`
 }
 
 type SummarizerResponse struct {
 	Articles []AIResponse `json:"articles"`
 	AiModel  string       `json:"ai_model"`
 	Category string       `json:"category"`
+	// PromptVersion is the version of the summarizer prompt used.
+	PromptVersion string `json:"prompt_version"`
 }
 
 type GeminiResponse struct {
 	Articles []AIResponse `json:"articles"`
 }
 
-func Summarize(payload []Summarizer) (*SummarizerResponse, error) {
+// needsTranslation reports whether any article was detected to be in a
+// language other than Indonesian.
+func needsTranslation(payload []Summarizer) bool {
+	return lo.SomeBy(payload, func(article Summarizer) bool {
+		return article.Language != "" && article.Language != LanguageIndonesian && article.Language != LanguageUndetermined
+	})
+}
+
+func Summarize(ctx context.Context, payload []Summarizer) (_ *SummarizerResponse, err error) {
+	aiModel := "gemini-2.0-flash"
+	ctx, span := startSpan(ctx, "summarizer", attribute.Int("articles", len(payload)))
+	defer func() {
+		if err != nil {
+			err = &LLMError{Operation: "summarize", Model: aiModel, Err: err}
+		}
+		endSpan(span, err)
+	}()
+
 	logger.Debug("Summarizing articles", "articles", payload)
 	if len(payload) == 0 {
 		return nil, fmt.Errorf("no articles to summarize")
 	}
 
-	ctx := context.Background()
 	client, err := genai.NewClient(ctx, &genai.ClientConfig{
 		APIKey:  os.Getenv("GEMINI_API_KEY"),
 		Backend: genai.BackendGeminiAPI,
@@ -49,23 +78,73 @@ func Summarize(payload []Summarizer) (*SummarizerResponse, error) {
 		return nil, fmt.Errorf("error creating client: %v", err)
 	}
 
+	// foreign wire copy is translated explicitly instead of relying on the
+	// model to notice it
+	data := newPromptData()
+	data.Translate = needsTranslation(payload)
+	prompt, err := LoadPrompt(PromptSummarizer, data)
+	if err != nil {
+		return nil, err
+	}
+	span.SetAttributes(attribute.String("prompt.version", prompt.Version))
+	if data.Translate {
+		span.SetAttributes(attribute.Bool("translate", true))
+		logger.Info("Summarizing with translation", "languages", lo.Uniq(lo.Map(payload, func(article Summarizer, _ int) string {
+			return article.Language
+		})))
+	}
+
 	// parse payload to string
 	jsonPayload, err := json.Marshal(payload)
 	if err != nil {
 		return nil, fmt.Errorf("error marshaling payload: %v", err)
 	}
 
+	// subcategories are optional, the property is only offered when the
+	// taxonomy has any
+	articleSchema := &genai.Schema{
+		Type: genai.TypeObject,
+		Properties: map[string]*genai.Schema{
+			"title": {
+				Type: genai.TypeString,
+			},
+			"excerpt": {
+				Type: genai.TypeString,
+			},
+			"long_content": {
+				Type: genai.TypeString,
+			},
+			"sources": {
+				Type: genai.TypeArray,
+				Items: &genai.Schema{
+					Type: genai.TypeString,
+				},
+			},
+			"category": {
+				Type: genai.TypeString,
+				Enum: categories(),
+			},
+		},
+		Required: []string{"title", "excerpt", "long_content", "sources", "category"},
+	}
+	if slugs := subcategories(); len(slugs) > 0 {
+		articleSchema.Properties["subcategory"] = &genai.Schema{
+			Type: genai.TypeString,
+			Enum: slugs,
+		}
+	}
+
 	logger.Info("Generating content")
 	parts := []*genai.Part{
 		{Text: string(jsonPayload)},
 	}
-	aiModel := "gemini-2.0-flash"
+	start := time.Now()
 	result, err := client.Models.GenerateContent(
-		ctx, "gemini-2.0-flash",
+		ctx, aiModel,
 		[]*genai.Content{genai.NewContentFromParts(parts, genai.RoleUser)}, &genai.GenerateContentConfig{
 			SystemInstruction: &genai.Content{
 				Parts: []*genai.Part{
-					{Text: systemInstructionSummarizer},
+					{Text: prompt.Text},
 				},
 			},
 			ResponseMIMEType: "application/json",
@@ -73,37 +152,14 @@ func Summarize(payload []Summarizer) (*SummarizerResponse, error) {
 				Type: genai.TypeObject,
 				Properties: map[string]*genai.Schema{
 					"articles": {
-						Type: genai.TypeArray,
-						Items: &genai.Schema{
-							Type: genai.TypeObject,
-							Properties: map[string]*genai.Schema{
-								"title": {
-									Type: genai.TypeString,
-								},
-								"excerpt": {
-									Type: genai.TypeString,
-								},
-								"long_content": {
-									Type: genai.TypeString,
-								},
-								"sources": {
-									Type: genai.TypeArray,
-									Items: &genai.Schema{
-										Type: genai.TypeString,
-									},
-								},
-								"category": {
-									Type: genai.TypeString,
-									Enum: []string{"national", "international", "entertainment", "sports", "technology", "business", "politics"},
-								},
-							},
-							Required: []string{"title", "excerpt", "long_content", "sources", "category"},
-						},
+						Type:  genai.TypeArray,
+						Items: articleSchema,
 					},
 				},
 				Required: []string{"articles"},
 			},
 		})
+	observeLLM(ctx, "summarize", aiModel, start, result, err)
 	if err != nil {
 		return nil, fmt.Errorf("error generating content: %v", err)
 	}
@@ -120,7 +176,8 @@ func Summarize(payload []Summarizer) (*SummarizerResponse, error) {
 	}
 
 	return &SummarizerResponse{
-		Articles: response.Articles,
-		AiModel:  aiModel,
+		Articles:      response.Articles,
+		AiModel:       aiModel,
+		PromptVersion: prompt.Version,
 	}, nil
 }
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/samber/lo"
+)
+
+type PageType string
+
+const (
+	PageArticle   PageType = "article"
+	PageVideo     PageType = "video"
+	PageLiveBlog  PageType = "liveblog"
+	PageGallery   PageType = "gallery"
+	PagePaywalled PageType = "paywalled"
+)
+
+const (
+	// PageActionKeep extracts the page like any article.
+	PageActionKeep = "keep"
+	// PageActionSkip drops the page without extracting it.
+	PageActionSkip = "skip"
+	// PageActionTeaser keeps the visible part of the page only, flagged by
+	// its page type.
+	PageActionTeaser = "teaser"
+	// PageActionEntries extracts the entries of a live blog separately
+	// instead of the page text.
+	PageActionEntries = "entries"
+)
+
+const defaultLiveBlogMaxEntries = 10
+
+var defaultPageActions = map[PageType]string{
+	PageArticle:   PageActionKeep,
+	PageVideo:     PageActionSkip,
+	PageGallery:   PageActionSkip,
+	PagePaywalled: PageActionTeaser,
+	PageLiveBlog:  PageActionEntries,
+}
+
+func pageActionFor(pageType PageType) string {
+	if action, ok := config.PageTypes[pageType]; ok {
+		return action
+	}
+	return lo.CoalesceOrEmpty(defaultPageActions[pageType], PageActionKeep)
+}
+
+// LiveBlogEntry is one update of a live blog.
+type LiveBlogEntry struct {
+	Headline    string    `json:"headline,omitempty"`
+	Body        string    `json:"body"`
+	PublishedAt time.Time `json:"published_at,omitzero"`
+}
+
+var paywallSelectors = `.paywall, [class*="paywall"], [data-paywall], .premium-content, .content-premium, .kompasid-paywall`
+
+var (
+	videoPathSegments    = []string{"video", "videos", "tv", "vidio"}
+	liveBlogPathSegments = []string{"live", "liveblog", "live-report", "live-update", "laporan-langsung"}
+	galleryPathSegments  = []string{"foto", "galeri", "gallery", "photo", "photos", "infografis"}
+)
+
+// ClassifyPage tells articles apart from video, live blog, gallery and
+// paywalled pages using JSON-LD types, paywall markers, og:type and the URL
+// path, in that order.
+func ClassifyPage(doc *goquery.Document, pageURL string) PageType {
+	var types []string
+	paywalled := false
+	for _, object := range jsonLDObjects(doc) {
+		objectTypes := jsonLDStrings(object["@type"])
+		types = append(types, objectTypes...)
+		free := object["isAccessibleForFree"]
+		if free == false || strings.EqualFold(jsonLDString(free), "false") {
+			paywalled = true
+		}
+	}
+	isArticle := lo.SomeBy(types, func(kind string) bool {
+		return lo.Contains(articleTypes, kind) && kind != "LiveBlogPosting"
+	})
+
+	switch {
+	case lo.Contains(types, "LiveBlogPosting"):
+		return PageLiveBlog
+	case paywalled || doc.Find(paywallSelectors).Length() > 0:
+		return PagePaywalled
+	case !isArticle && lo.Contains(types, "VideoObject"):
+		return PageVideo
+	case !isArticle && lo.Contains(types, "ImageGallery"):
+		return PageGallery
+	case strings.HasPrefix(metaContent(doc, `meta[property="og:type"]`), "video"):
+		return PageVideo
+	}
+
+	if parsed, err := url.Parse(pageURL); err == nil {
+		segments := strings.Split(strings.ToLower(parsed.Path), "/")
+		for _, segment := range segments {
+			switch {
+			case slices.Contains(videoPathSegments, segment):
+				return PageVideo
+			case slices.Contains(liveBlogPathSegments, segment):
+				return PageLiveBlog
+			case slices.Contains(galleryPathSegments, segment):
+				return PageGallery
+			}
+		}
+		// e.g. video.kompas.com or foto.kompas.com
+		subdomain, _, _ := strings.Cut(parsed.Hostname(), ".")
+		switch {
+		case slices.Contains(videoPathSegments, subdomain):
+			return PageVideo
+		case slices.Contains(galleryPathSegments, subdomain):
+			return PageGallery
+		}
+	}
+	return PageArticle
+}
+
+var liveBlogEntrySelectors = `.live-update, .liveblog-entry, .live-blog__item, [class*="live-post"]`
+
+// liveBlogEntries reads the updates of a live blog, newest first, from the
+// liveBlogUpdate JSON-LD property or, failing that, from the page markup.
+func liveBlogEntries(doc *goquery.Document) []LiveBlogEntry {
+	var entries []LiveBlogEntry
+	for _, object := range jsonLDObjects(doc) {
+		if !lo.Contains(jsonLDStrings(object["@type"]), "LiveBlogPosting") {
+			continue
+		}
+		updates, ok := object["liveBlogUpdate"].([]any)
+		if !ok {
+			if update, ok := object["liveBlogUpdate"].(map[string]any); ok {
+				updates = []any{update}
+			}
+		}
+		for _, item := range updates {
+			update, ok := item.(map[string]any)
+			if !ok {
+				continue
+			}
+			entry := LiveBlogEntry{
+				Headline:    jsonLDString(update["headline"]),
+				Body:        jsonHTMLText(jsonLDString(update["articleBody"])),
+				PublishedAt: parseMetaTime(jsonLDString(update["datePublished"])),
+			}
+			if entry.Body != "" || entry.Headline != "" {
+				entries = append(entries, entry)
+			}
+		}
+	}
+
+	if len(entries) == 0 {
+		doc.Find(liveBlogEntrySelectors).Each(func(i int, s *goquery.Selection) {
+			body := strings.TrimSpace(whitespaceRun.ReplaceAllString(s.Find("p").Text(), " "))
+			if body == "" {
+				body = strings.TrimSpace(whitespaceRun.ReplaceAllString(s.Text(), " "))
+			}
+			if body == "" {
+				return
+			}
+			datetime := s.Find("time[datetime]").First().AttrOr("datetime", "")
+			entries = append(entries, LiveBlogEntry{
+				Headline:    strings.TrimSpace(s.Find("h2, h3").First().Text()),
+				Body:        body,
+				PublishedAt: parseMetaTime(datetime),
+			})
+		})
+	}
+
+	// entries without a time keep their page order after the dated ones
+	slices.SortStableFunc(entries, func(a, b LiveBlogEntry) int {
+		return b.PublishedAt.Compare(a.PublishedAt)
+	})
+	return lo.Subset(entries, 0, uint(envInt("LIVEBLOG_MAX_ENTRIES", defaultLiveBlogMaxEntries)))
+}
+
+// liveBlogContent joins the entries of a live blog into article text.
+func liveBlogContent(entries []LiveBlogEntry) string {
+	parts := lo.Map(entries, func(entry LiveBlogEntry, _ int) string {
+		if entry.Headline == "" {
+			return entry.Body
+		}
+		return entry.Headline + ". " + entry.Body
+	})
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"resty.dev/v3"
+)
+
+const (
+	PaginationSuffix = "suffix"
+	PaginationNext   = "next"
+	PaginationJSON   = "json"
+)
+
+const (
+	defaultArticleMaxPages = 5
+	defaultNextSelector    = `link[rel=next], a[rel=next]`
+)
+
+// defaultPagination is used for publishers without an entry in the
+// pagination section of the config.
+var defaultPagination = map[string]PaginationConfig{
+	"Kompas":   {Strategy: PaginationSuffix, Suffix: "?page=all"},
+	"Kumparan": {Strategy: PaginationSuffix, Suffix: "/full"},
+	"Liputan6": {Strategy: PaginationNext},
+	"CNN":      {Strategy: PaginationNext},
+	"CNBC":     {Strategy: PaginationNext},
+	"Detik":    {Strategy: PaginationSuffix, Suffix: "?single=1"},
+	"Tempo":    {Strategy: PaginationNext},
+	"Tribun":   {Strategy: PaginationSuffix, Suffix: "?page=all"},
+}
+
+func paginationFor(publisher string) PaginationConfig {
+	pagination, ok := config.Pagination[publisher]
+	if !ok {
+		pagination = defaultPagination[publisher]
+	}
+	if pagination.MaxPages <= 0 {
+		pagination.MaxPages = envInt("ARTICLE_MAX_PAGES", defaultArticleMaxPages)
+	}
+	if pagination.NextSelector == "" {
+		pagination.NextSelector = defaultNextSelector
+	}
+	return pagination
+}
+
+var whitespaceRun = regexp.MustCompile(`\s{2,}`)
+
+// fetchArticle fetches every page of an article following the publisher's
+// pagination strategy and stitches the text extracted from each page.
+// Metadata, images and the page type are taken from the first page; pages
+// that are not plain articles are not followed (see pageActionFor).
+func fetchArticle(ctx context.Context, articleURL string, client *resty.Client, extractor ArticleExtractor) (*ArticlePage, error) {
+	publisher := normalizeSource(articleURL)
+	pagination := paginationFor(publisher)
+
+	firstURL := articleURL
+	if pagination.Strategy == PaginationSuffix {
+		firstURL = withSuffix(articleURL, pagination.Suffix)
+	}
+	doc, err := fetchDocument(ctx, firstURL, client)
+	if err != nil {
+		return nil, err
+	}
+	cleaner := NewCleaner(publisher)
+	page := parseArticlePage(doc, articleURL, firstURL, extractor, cleaner)
+
+	pages := 1
+	if pageActionFor(page.Type) == PageActionKeep {
+		switch pagination.Strategy {
+		case PaginationNext:
+			more := followNextPages(ctx, firstURL, doc, client, pagination, func(doc *goquery.Document) string {
+				cleaner.CleanDocument(doc)
+				return extractor.Content(doc)
+			})
+			pages += len(more)
+			page.Content = strings.Join(append([]string{page.Content}, more...), " ")
+		case PaginationJSON:
+			contents, err := fetchJSONPages(ctx, articleURL, client, pagination)
+			if err != nil {
+				return nil, err
+			}
+			pages = len(contents)
+			page.Content = strings.Join(contents, " ")
+		}
+	}
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int("pages", pages))
+
+	page.Content = cleaner.CleanText(page.Content)
+	if len(cleaner.Stripped) > 0 {
+		logger.Debug("Stripped boilerplate", "url", articleURL, "total", cleaner.Total(), "rules", cleaner.Stripped)
+	}
+	return page, nil
+}
+
+// parseArticlePage classifies a fetched page and reads its metadata,
+// images and, unless it is skipped, its text. Boilerplate is removed from
+// the page before the text is extracted, but after classification which
+// relies on scripts the cleaner strips.
+func parseArticlePage(doc *goquery.Document, articleURL string, pageURL string, extractor ArticleExtractor, cleaner *Cleaner) *ArticlePage {
+	page := &ArticlePage{
+		Type:     ClassifyPage(doc, articleURL),
+		Metadata: ExtractMetadata(doc),
+		Images:   articleImages(doc, pageURL, extractor.Images),
+	}
+	switch pageActionFor(page.Type) {
+	case PageActionSkip:
+	case PageActionEntries:
+		page.Entries = liveBlogEntries(doc)
+		page.Content = liveBlogContent(page.Entries)
+	default:
+		cleaner.CleanDocument(doc)
+		page.Content = extractor.Content(doc)
+	}
+	return page
+}
+
+// withSuffix adds the suffix of the suffix strategy to articleURL, setting
+// the parameters of a query suffix so a URL that already has a query keeps
+// a single one.
+func withSuffix(articleURL string, suffix string) string {
+	parsed, err := url.Parse(articleURL)
+	if err != nil || suffix == "" {
+		return articleURL + suffix
+	}
+	if query, ok := strings.CutPrefix(suffix, "?"); ok {
+		values := parsed.Query()
+		suffixValues, err := url.ParseQuery(query)
+		if err != nil {
+			return articleURL
+		}
+		for name := range suffixValues {
+			values.Set(name, suffixValues.Get(name))
+		}
+		parsed.RawQuery = values.Encode()
+		return parsed.String()
+	}
+	parsed.Path = strings.TrimSuffix(parsed.Path, "/") + suffix
+	parsed.RawPath = ""
+	return parsed.String()
+}
+
+// followNextPages extracts the pages linked as next from doc, which was
+// already extracted, until there is no next link, a page repeats or
+// MaxPages is reached. A page that cannot be fetched ends the article with
+// the pages read so far.
+func followNextPages(ctx context.Context, articleURL string, doc *goquery.Document, client *resty.Client, pagination PaginationConfig, extract func(*goquery.Document) string) []string {
+	seen := map[string]bool{articleURL: true}
+	pageURL := articleURL
+	var contents []string
+	for len(contents)+1 < pagination.MaxPages {
+		next := nextPageURL(doc, pageURL, pagination.NextSelector)
+		if next == "" || seen[next] || !samePagedArticle(articleURL, next) {
+			break
+		}
+		seen[next] = true
+
+		var err error
+		doc, err = fetchDocument(ctx, next, client)
+		if err != nil {
+			logger.Warn("Error fetching next page, keeping the pages read so far", "url", next, "pages", len(contents)+1, "error", err)
+			break
+		}
+		pageURL = next
+		contents = append(contents, extract(doc))
+	}
+	return contents
+}
+
+// samePagedArticle reports whether next is a page of the article at
+// articleURL: on the same host and under its path, so a next link pointing
+// to another story is not stitched to the article.
+func samePagedArticle(articleURL string, next string) bool {
+	article, err := url.Parse(articleURL)
+	if err != nil {
+		return false
+	}
+	page, err := url.Parse(next)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(article.Hostname(), page.Hostname()) &&
+		strings.HasPrefix(page.Path, strings.TrimSuffix(article.Path, "/"))
+}
+
+func nextPageURL(doc *goquery.Document, pageURL string, selector string) string {
+	href := strings.TrimSpace(doc.Find(selector).First().AttrOr("href", ""))
+	if href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(href, "javascript:") {
+		return ""
+	}
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return ""
+	}
+	next, err := base.Parse(href)
+	if err != nil {
+		return ""
+	}
+	next.Fragment = ""
+	return next.String()
+}
+
+// fetchJSONPages reads the article text from a JSON endpoint. Templates
+// with {page} are requested page by page until a page has no content or
+// cannot be fetched, usually a 404 after the last page; otherwise
+// NextField, when set, links the following page.
+func fetchJSONPages(ctx context.Context, articleURL string, client *resty.Client, pagination PaginationConfig) ([]string, error) {
+	if pagination.Endpoint == "" || pagination.ContentField == "" {
+		return nil, &ParseError{URL: articleURL, Publisher: normalizeSource(articleURL), Err: errors.New("json pagination needs an endpoint and a content field")}
+	}
+	parsed, err := url.Parse(articleURL)
+	if err != nil {
+		return nil, &ParseError{URL: articleURL, Publisher: normalizeSource(articleURL), Err: err}
+	}
+	replacer := strings.NewReplacer("{url}", url.QueryEscape(articleURL), "{path}", parsed.Path)
+	endpoint := replacer.Replace(pagination.Endpoint)
+	numbered := strings.Contains(endpoint, "{page}")
+
+	var contents []string
+	next := strings.ReplaceAll(endpoint, "{page}", "1")
+	for next != "" && len(contents) < pagination.MaxPages {
+		data, err := fetchJSON(ctx, next, client)
+		if err != nil && len(contents) > 0 {
+			logger.Debug("Stopping json pagination", "url", next, "pages", len(contents), "error", err)
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		content := jsonHTMLText(lookupJSONField(data, pagination.ContentField))
+		if content == "" {
+			break
+		}
+		contents = append(contents, content)
+
+		switch {
+		case numbered:
+			next = strings.ReplaceAll(endpoint, "{page}", strconv.Itoa(len(contents)+1))
+		case pagination.NextField != "":
+			value, _ := lookupJSONField(data, pagination.NextField).(string)
+			next = value
+		default:
+			next = ""
+		}
+	}
+	if len(contents) == 0 {
+		return nil, &ParseError{URL: endpoint, Publisher: normalizeSource(articleURL), Err: errors.New("json endpoint returned no content")}
+	}
+	return contents, nil
+}
+
+func fetchJSON(ctx context.Context, endpoint string, client *resty.Client) (any, error) {
+	publisher := normalizeSource(endpoint)
+	response, err := client.R().
+		SetContext(ctx).
+		SetHeader("Accept", "application/json").
+		Get(endpoint)
+	if err != nil {
+		return nil, &ArticleFetchError{URL: endpoint, Publisher: publisher, Err: err}
+	}
+	defer response.Body.Close()
+	if response.IsError() {
+		return nil, &ArticleFetchError{URL: endpoint, Publisher: publisher, StatusCode: response.StatusCode(), Err: errors.New(response.Status())}
+	}
+
+	var data any
+	if err := json.NewDecoder(response.Body).Decode(&data); err != nil {
+		return nil, &ParseError{URL: endpoint, Publisher: publisher, Err: fmt.Errorf("error decoding json: %v", err)}
+	}
+	return data, nil
+}
+
+// lookupJSONField follows a dot separated path such as "data.story.body"
+// or "pages.0.html" into decoded JSON.
+func lookupJSONField(data any, path string) any {
+	for _, key := range strings.Split(path, ".") {
+		switch value := data.(type) {
+		case map[string]any:
+			data = value[key]
+		case []any:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(value) {
+				return nil
+			}
+			data = value[index]
+		default:
+			return nil
+		}
+	}
+	return data
+}
+
+// jsonHTMLText returns the text of the paragraphs of an HTML fragment, or
+// of the whole fragment when it has none. Arrays are joined.
+func jsonHTMLText(value any) string {
+	switch v := value.(type) {
+	case string:
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(v))
+		if err != nil {
+			return ""
+		}
+		paragraphs := doc.Find("p")
+		if paragraphs.Length() == 0 {
+			return strings.TrimSpace(doc.Text())
+		}
+		var content string
+		paragraphs.Each(func(i int, s *goquery.Selection) {
+			content += s.Text() + " "
+		})
+		return strings.TrimSpace(content)
+	case []any:
+		var parts []string
+		for _, item := range v {
+			if text := jsonHTMLText(item); text != "" {
+				parts = append(parts, text)
+			}
+		}
+		return strings.Join(parts, " ")
+	}
+	return ""
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"resty.dev/v3"
+)
+
+func TestWithSuffix(t *testing.T) {
+	tests := []struct {
+		url    string
+		suffix string
+		want   string
+	}{
+		{"https://www.kompas.com/read/1/banjir", "?page=all", "https://www.kompas.com/read/1/banjir?page=all"},
+		{"https://www.kompas.com/read/1/banjir?utm_source=rss", "?page=all", "https://www.kompas.com/read/1/banjir?page=all&utm_source=rss"},
+		{"https://news.detik.com/berita/d-1/banjir?single=0", "?single=1", "https://news.detik.com/berita/d-1/banjir?single=1"},
+		{"https://kumparan.com/kumparannews/banjir-24abc/", "/full", "https://kumparan.com/kumparannews/banjir-24abc/full"},
+		{"https://kumparan.com/kumparannews/banjir-24abc?ref=rss", "/full", "https://kumparan.com/kumparannews/banjir-24abc/full?ref=rss"},
+	}
+	for _, test := range tests {
+		if got := withSuffix(test.url, test.suffix); got != test.want {
+			t.Errorf("withSuffix(%q, %q) = %q, want %q", test.url, test.suffix, got, test.want)
+		}
+	}
+}
+
+func TestSamePagedArticle(t *testing.T) {
+	article := "https://www.cnnindonesia.com/nasional/20250512-20-1/dpr-gelar-rapat"
+	tests := []struct {
+		next string
+		want bool
+	}{
+		{"https://www.cnnindonesia.com/nasional/20250512-20-1/dpr-gelar-rapat/2", true},
+		{"https://www.cnnindonesia.com/nasional/20250512-20-1/dpr-gelar-rapat?page=2", true},
+		{"https://www.cnnindonesia.com/nasional/20250512-20-2/presiden-resmikan-tol", false},
+		{"https://www.cnbcindonesia.com/nasional/20250512-20-1/dpr-gelar-rapat/2", false},
+	}
+	for _, test := range tests {
+		if got := samePagedArticle(article, test.next); got != test.want {
+			t.Errorf("samePagedArticle(%q) = %t, want %t", test.next, got, test.want)
+		}
+	}
+}
+
+func TestFollowNextPages(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/story/1", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<link rel="next" href="/story/1/2"><p>satu</p>`)
+	})
+	mux.HandleFunc("/story/1/2", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<link rel="next" href="/story/1/3"><p>dua</p>`)
+	})
+	// the third page fails, the first two are kept
+	mux.HandleFunc("/story/1/3", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "gone", http.StatusGone)
+	})
+	mux.HandleFunc("/story/2", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<link rel="next" href="/story/3"><p>dua</p>`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := resty.New()
+	defer client.Close()
+
+	extract := func(doc *goquery.Document) string {
+		return doc.Find("p").Text()
+	}
+	pagination := PaginationConfig{MaxPages: 5, NextSelector: defaultNextSelector}
+	tests := []struct {
+		page string
+		want []string
+	}{
+		{`<link rel="next" href="/story/1/2">`, []string{"dua"}},
+		{`<link rel="next" href="/story/2">`, nil},
+	}
+	for _, test := range tests {
+		doc, _ := goquery.NewDocumentFromReader(strings.NewReader(test.page))
+		got := followNextPages(t.Context(), server.URL+"/story/1", doc, client, pagination, extract)
+		if strings.Join(got, ",") != strings.Join(test.want, ",") {
+			t.Errorf("followNextPages(%s) = %v, want %v", test.page, got, test.want)
+		}
+	}
+}
+
+func TestFetchJSONPages(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := r.URL.Query().Get("page")
+		if page != "1" && page != "2" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `{"data": {"body": "<p>halaman %s</p>"}}`, page)
+	}))
+	defer server.Close()
+	client := resty.New()
+	defer client.Close()
+
+	pagination := PaginationConfig{Strategy: PaginationJSON, Endpoint: server.URL + "/api?page={page}", ContentField: "data.body", MaxPages: 5}
+	contents, err := fetchJSONPages(t.Context(), "https://kumparan.com/kumparannews/banjir-24abc", client, pagination)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(contents, ","); got != "halaman 1,halaman 2" {
+		t.Errorf("contents %q", got)
+	}
+
+	pagination.Endpoint = server.URL + "/missing?page=0{page}"
+	if _, err := fetchJSONPages(t.Context(), "https://kumparan.com/kumparannews/banjir-24abc", client, pagination); err == nil {
+		t.Errorf("a missing first page is not an error")
+	}
+}
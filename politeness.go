@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	crawlerAgent        = "ngopibentar-crawler"
+	robotsCacheTTL      = 24 * time.Hour
+	robotsErrorCacheTTL = 10 * time.Minute
+	defaultHostRate     = 1.0
+	defaultHostMinDelay = time.Second
+	robotsFetchTimeout  = 10 * time.Second
+	// maxRobotsRedirects is the number of redirects followed for a
+	// robots.txt, as in RFC 9309.
+	maxRobotsRedirects = 5
+)
+
+var ErrDisallowedByRobots = errors.New("disallowed by robots.txt")
+
+// userAgent returns the User-Agent sent with every crawler request. It
+// always identifies the crawler so publishers can reach us.
+func userAgent() string {
+	if agent := os.Getenv("CRAWLER_USER_AGENT"); agent != "" {
+		return agent
+	}
+	return fmt.Sprintf("%s/1.0 (+%s)", crawlerAgent, siteURL())
+}
+
+type hostState struct {
+	mu      sync.Mutex
+	next    time.Time
+	robots  *Robots
+	expires time.Time
+}
+
+// PoliteTransport is an http.RoundTripper enforcing a per-host request rate
+// and minimum delay, and honoring robots.txt including Crawl-delay.
+type PoliteTransport struct {
+	base          http.RoundTripper
+	interval      time.Duration
+	respectRobots bool
+
+	mu    sync.Mutex
+	hosts map[string]*hostState
+}
+
+func NewPoliteTransport(base http.RoundTripper, rate float64, minDelay time.Duration, respectRobots bool) *PoliteTransport {
+	interval := minDelay
+	if rate > 0 {
+		interval = max(interval, time.Duration(float64(time.Second)/rate))
+	}
+	return &PoliteTransport{
+		base:          base,
+		interval:      interval,
+		respectRobots: respectRobots,
+		hosts:         map[string]*hostState{},
+	}
+}
+
+func (t *PoliteTransport) host(host string) *hostState {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	state, ok := t.hosts[host]
+	if !ok {
+		state = &hostState{}
+		t.hosts[host] = state
+	}
+	return state
+}
+
+// robots returns the cached robots.txt of the request's host, fetching it
+// when missing or expired. Following RFC 9309, a missing file (4xx) allows
+// everything and an unreachable one (5xx or network error) disallows
+// everything until the next attempt.
+func (t *PoliteTransport) robots(ctx context.Context, req *http.Request, state *hostState) *Robots {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if state.robots != nil && time.Now().Before(state.expires) {
+		return state.robots
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, robotsFetchTimeout)
+	defer cancel()
+	robotsURL := req.URL.Scheme + "://" + req.URL.Host + "/robots.txt"
+	resp, err := t.fetchRobots(ctx, robotsURL, req.Header.Get("User-Agent"))
+	switch {
+	case err != nil:
+		logger.Warn("Error fetching robots.txt", "url", robotsURL, "error", err)
+		state.robots, state.expires = &Robots{disallowAll: true}, time.Now().Add(robotsErrorCacheTTL)
+	case resp.StatusCode >= 500:
+		logger.Warn("robots.txt unavailable", "url", robotsURL, "status", resp.StatusCode)
+		state.robots, state.expires = &Robots{disallowAll: true}, time.Now().Add(robotsErrorCacheTTL)
+	case resp.StatusCode >= 300:
+		// 4xx and too many redirects mean there is no robots.txt
+		state.robots, state.expires = &Robots{}, time.Now().Add(robotsCacheTTL)
+	default:
+		state.robots, state.expires = ParseRobots(resp.Body), time.Now().Add(robotsCacheTTL)
+	}
+	if resp != nil {
+		resp.Body.Close()
+	}
+	logger.Debug("robots.txt loaded", "url", robotsURL)
+	return state.robots
+}
+
+// fetchRobots requests a robots.txt, following up to maxRobotsRedirects
+// redirects as RFC 9309 asks. The last redirect is returned when there are
+// more.
+func (t *PoliteTransport) fetchRobots(ctx context.Context, robotsURL string, agent string) (*http.Response, error) {
+	for redirects := 0; ; redirects++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, robotsURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("User-Agent", agent)
+		resp, err := t.base.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		location, err := resp.Location()
+		if resp.StatusCode < 300 || resp.StatusCode >= 400 || err != nil || redirects == maxRobotsRedirects {
+			return resp, nil
+		}
+		resp.Body.Close()
+		robotsURL = location.String()
+	}
+}
+
+// wait reserves the next slot for the host and sleeps until it.
+func (t *PoliteTransport) wait(ctx context.Context, state *hostState, interval time.Duration) error {
+	state.mu.Lock()
+	now := time.Now()
+	slot := state.next
+	if slot.Before(now) {
+		slot = now
+	}
+	state.next = slot.Add(interval)
+	state.mu.Unlock()
+
+	delay := time.Until(slot)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (t *PoliteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	state := t.host(req.URL.Host)
+	agent := req.Header.Get("User-Agent")
+
+	interval := t.interval
+	if t.respectRobots {
+		robots := t.robots(ctx, req, state)
+		if !robots.Allowed(agent, req.URL.RequestURI()) {
+			return nil, fmt.Errorf("%s: %w", req.URL, ErrDisallowedByRobots)
+		}
+		interval = max(interval, robots.CrawlDelay(agent))
+	}
+
+	if err := t.wait(ctx, state, interval); err != nil {
+		return nil, err
+	}
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,370 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+	"time"
+
+	"github.com/samber/lo"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+//go:embed prompts/*.tmpl
+var promptFiles embed.FS
+
+const (
+	PromptSummarizer = "summarizer"
+	PromptGrouper    = "grouper"
+	PromptTranslator = "translator"
+	PromptDigest     = "digest"
+)
+
+var promptNames = []string{PromptSummarizer, PromptGrouper, PromptTranslator, PromptDigest}
+
+// PromptData are the variables available to the prompt templates.
+type PromptData struct {
+	Categories []TaxonomyCategory
+	Date       string
+	// Language is the name of the target language of the translator.
+	Language string
+	// Translate asks the summarizer to translate non-Indonesian input.
+	Translate bool
+	// MinParagraphs and MaxParagraphs bound the long_content of a story, as
+	// checked by ValidateSummary.
+	MinParagraphs int
+	MaxParagraphs int
+}
+
+// Prompt is a rendered system instruction. Version is derived from the
+// template source and the settings it is rendered with, so it changes with
+// the wording or the taxonomy but not with the date.
+type Prompt struct {
+	Name     string
+	Version  string
+	Source   string
+	Settings string
+	Text     string
+}
+
+// PromptVersion is a template source and settings seen by a crawl run.
+type PromptVersion struct {
+	Name      string
+	Version   string
+	Source    string
+	Settings  string
+	Articles  int
+	CreatedAt time.Time
+}
+
+// promptSource reads the template of a prompt, from PROMPTS_DIR when set so
+// the wording can be tuned without a rebuild, otherwise from the binary.
+func promptSource(name string) (string, error) {
+	var source []byte
+	var err error
+	if dir := os.Getenv("PROMPTS_DIR"); dir != "" {
+		source, err = os.ReadFile(filepath.Join(dir, name+".tmpl"))
+	} else {
+		source, err = fs.ReadFile(promptFiles, "prompts/"+name+".tmpl")
+	}
+	if err != nil {
+		return "", fmt.Errorf("error reading prompt %s: %v", name, err)
+	}
+	return string(source), nil
+}
+
+// promptSettings are the configured variables of data, as opposed to the
+// ones set per request such as the date, as indented JSON.
+func promptSettings(data PromptData) string {
+	settings, err := json.MarshalIndent(map[string]any{
+		"categories":     data.Categories,
+		"min_paragraphs": data.MinParagraphs,
+		"max_paragraphs": data.MaxParagraphs,
+	}, "", "  ")
+	if err != nil {
+		return ""
+	}
+	return string(settings)
+}
+
+func promptVersion(source string, settings string) string {
+	sum := sha256.Sum256([]byte(source + "\x00" + settings))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// promptText is a recorded prompt as shown and diffed by the prompts
+// command, the template followed by its settings.
+func promptText(source string, settings string) string {
+	if settings == "" {
+		return source
+	}
+	return strings.TrimSuffix(source, "\n") + "\n\n---- settings ----\n" + settings + "\n"
+}
+
+// newPromptData fills the variables shared by every prompt.
+func newPromptData() PromptData {
+	minParagraphs, maxParagraphs := summaryParagraphs()
+	return PromptData{
+		Categories:    taxonomy(),
+		Date:          time.Now().In(jakarta).Format("2 January 2006"),
+		MinParagraphs: minParagraphs,
+		MaxParagraphs: maxParagraphs,
+	}
+}
+
+// LoadPrompt renders the prompt template name with data.
+func LoadPrompt(name string, data PromptData) (*Prompt, error) {
+	source, err := promptSource(name)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := template.New(name).Funcs(template.FuncMap{
+		"join": strings.Join,
+		"slugs": func(categories []TaxonomyCategory) []string {
+			return lo.Map(categories, func(category TaxonomyCategory, _ int) string {
+				return category.Slug
+			})
+		},
+	}).Parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing prompt %s: %v", name, err)
+	}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return nil, fmt.Errorf("error rendering prompt %s: %v", name, err)
+	}
+	settings := promptSettings(data)
+	return &Prompt{Name: name, Version: promptVersion(source, settings), Source: source, Settings: settings, Text: sb.String()}, nil
+}
+
+// RecordPromptVersions stores the current source and settings of every
+// prompt, so the version kept on articles can be looked up and diffed later.
+func RecordPromptVersions(ctx context.Context, db *sql.DB) (err error) {
+	ctx, span := startSpan(ctx, "store.record_prompt_versions")
+	defer func() {
+		if err != nil {
+			err = &StoreError{Operation: "recording prompt versions", Err: err}
+		}
+		endSpan(span, err)
+	}()
+
+	createdAt := time.Now().Format(createdAtLayout)
+	settings := promptSettings(newPromptData())
+	for _, name := range promptNames {
+		source, err := promptSource(name)
+		if err != nil {
+			return err
+		}
+		version := promptVersion(source, settings)
+		span.SetAttributes(attribute.String("prompt."+name, version))
+		_, err = db.ExecContext(ctx, `
+			INSERT OR IGNORE INTO prompt_versions (name, version, source, settings, created_at)
+			VALUES (?, ?, ?, ?, ?)
+		`, name, version, source, settings, createdAt)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListPromptVersions returns the recorded versions, newest first, with the
+// number of articles written with each summarizer version and of
+// translations written with each translator version.
+func ListPromptVersions(db *sql.DB, name string) ([]PromptVersion, error) {
+	stmt := `
+		SELECT p.name, p.version, p.source, p.settings, p.created_at,
+			(SELECT COUNT(*) FROM articles a WHERE p.name = ? AND a.prompt_version = p.version) +
+			(SELECT COUNT(*) FROM article_translations t WHERE p.name = ? AND t.prompt_version = p.version)
+		FROM prompt_versions p WHERE 1 = 1`
+	args := []any{PromptSummarizer, PromptTranslator}
+	if name != "" {
+		stmt += ` AND p.name = ?`
+		args = append(args, name)
+	}
+	stmt += ` ORDER BY p.name, p.created_at DESC`
+
+	rows, err := db.Query(stmt, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying prompt versions: %v", err)
+	}
+	defer rows.Close()
+
+	var versions []PromptVersion
+	for rows.Next() {
+		var version PromptVersion
+		var createdAt string
+		if err := rows.Scan(&version.Name, &version.Version, &version.Source, &version.Settings, &createdAt, &version.Articles); err != nil {
+			return nil, fmt.Errorf("error scanning prompt version: %v", err)
+		}
+		version.CreatedAt, err = time.ParseInLocation(createdAtLayout, createdAt, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing created_at of prompt %s %s: %v", version.Name, version.Version, err)
+		}
+		versions = append(versions, version)
+	}
+	return versions, rows.Err()
+}
+
+// promptVersionText returns the source and settings of a recorded version,
+// or of the current template when version is empty, with its version.
+func promptVersionText(db *sql.DB, name string, version string) (string, string, error) {
+	if version == "" {
+		source, err := promptSource(name)
+		if err != nil {
+			return "", "", err
+		}
+		settings := promptSettings(newPromptData())
+		return promptText(source, settings), promptVersion(source, settings), nil
+	}
+	var source, settings string
+	err := db.QueryRow(`SELECT source, settings FROM prompt_versions WHERE name = ? AND version = ?`, name, version).Scan(&source, &settings)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", "", fmt.Errorf("prompt %s has no version %s", name, version)
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("error querying prompt version: %v", err)
+	}
+	return promptText(source, settings), version, nil
+}
+
+// diffLines is a line based diff of two texts, prefixing removed lines with
+// "-", added lines with "+" and unchanged ones with a space.
+func diffLines(a, b string) []string {
+	from, to := strings.Split(a, "\n"), strings.Split(b, "\n")
+	// lcs[i][j] is the longest common subsequence of from[i:] and to[j:]
+	lcs := make([][]int, len(from)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(to)+1)
+	}
+	for i := len(from) - 1; i >= 0; i-- {
+		for j := len(to) - 1; j >= 0; j-- {
+			if from[i] == to[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var diff []string
+	i, j := 0, 0
+	for i < len(from) || j < len(to) {
+		switch {
+		case i < len(from) && j < len(to) && from[i] == to[j]:
+			diff = append(diff, "  "+from[i])
+			i++
+			j++
+		case i < len(from) && (j == len(to) || lcs[i+1][j] >= lcs[i][j+1]):
+			diff = append(diff, "- "+from[i])
+			i++
+		default:
+			diff = append(diff, "+ "+to[j])
+			j++
+		}
+	}
+	return diff
+}
+
+// diffHunks keeps the changed lines of diff with up to context unchanged
+// lines around them, separating the hunks with "@@".
+func diffHunks(diff []string, context int) []string {
+	keep := make([]bool, len(diff))
+	for i, line := range diff {
+		if strings.HasPrefix(line, "  ") {
+			continue
+		}
+		for j := max(0, i-context); j <= min(len(diff)-1, i+context); j++ {
+			keep[j] = true
+		}
+	}
+	var hunks []string
+	for i, line := range diff {
+		if !keep[i] {
+			continue
+		}
+		if i == 0 || !keep[i-1] {
+			hunks = append(hunks, "@@")
+		}
+		hunks = append(hunks, line)
+	}
+	return hunks
+}
+
+func runPrompts(db *sql.DB, args []string) error {
+	subcommand := "list"
+	if len(args) > 0 {
+		subcommand, args = args[0], args[1:]
+	}
+
+	flags := flag.NewFlagSet("prompts "+subcommand, flag.ExitOnError)
+	switch subcommand {
+	case "list":
+		name := flags.String("name", "", "only list versions of this prompt")
+		flags.Parse(args)
+		versions, err := ListPromptVersions(db, *name)
+		if err != nil {
+			return err
+		}
+
+		current := map[string]string{}
+		settings := promptSettings(newPromptData())
+		for _, name := range promptNames {
+			if source, err := promptSource(name); err == nil {
+				current[name] = promptVersion(source, settings)
+			}
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tVERSION\tCURRENT\tFIRST SEEN\tARTICLES")
+		for _, version := range versions {
+			fmt.Fprintf(w, "%s\t%s\t%t\t%s\t%d\n", version.Name, version.Version, current[version.Name] == version.Version,
+				version.CreatedAt.Format(createdAtLayout), version.Articles)
+		}
+		w.Flush()
+	case "show":
+		name := flags.String("name", PromptSummarizer, "prompt name")
+		version := flags.String("version", "", "prompt version, defaults to the current template")
+		flags.Parse(args)
+		text, _, err := promptVersionText(db, *name, *version)
+		if err != nil {
+			return err
+		}
+		fmt.Print(text)
+	case "diff":
+		name := flags.String("name", PromptSummarizer, "prompt name")
+		from := flags.String("from", "", "older prompt version")
+		to := flags.String("to", "", "newer prompt version, defaults to the current template")
+		flags.Parse(args)
+		if *from == "" {
+			return fmt.Errorf("from is required")
+		}
+
+		a, _, err := promptVersionText(db, *name, *from)
+		if err != nil {
+			return err
+		}
+		b, toVersion, err := promptVersionText(db, *name, *to)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("--- %s %s\n+++ %s %s\n", *name, *from, *name, toVersion)
+		for _, line := range diffHunks(diffLines(a, b), 2) {
+			fmt.Println(line)
+		}
+	default:
+		return fmt.Errorf("usage: prompts <list|show|diff> [flags]")
+	}
+	return nil
+}
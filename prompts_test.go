@@ -0,0 +1,90 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want []string
+	}{
+		{"a\nb", "a\nb", []string{"  a", "  b"}},
+		{"a\nb\nc", "a\nc", []string{"  a", "- b", "  c"}},
+		{"a\nc", "a\nb\nc", []string{"  a", "+ b", "  c"}},
+		{"a\nb", "a\nx", []string{"  a", "- b", "+ x"}},
+		{"", "a", []string{"- ", "+ a"}},
+	}
+	for _, test := range tests {
+		if got := diffLines(test.a, test.b); strings.Join(got, "|") != strings.Join(test.want, "|") {
+			t.Errorf("diffLines(%q, %q) = %q, want %q", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestDiffHunks(t *testing.T) {
+	diff := diffLines("1\n2\n3\n4\n5\n6\n7\n8\n9", "1\n2\nx\n4\n5\n6\n7\n8\ny")
+	want := []string{"@@", "  2", "- 3", "+ x", "  4", "@@", "  8", "- 9", "+ y"}
+	if got := diffHunks(diff, 1); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("diffHunks = %q, want %q", got, want)
+	}
+	if got := diffHunks(diffLines("a\nb", "a\nb"), 2); len(got) != 0 {
+		t.Errorf("diffHunks of equal texts = %q", got)
+	}
+}
+
+func TestPromptVersion(t *testing.T) {
+	defer func(taxonomy []TaxonomyCategory) { config.Taxonomy = taxonomy }(config.Taxonomy)
+	config.Taxonomy = nil
+
+	prompt, err := LoadPrompt(PromptSummarizer, newPromptData())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(prompt.Text, "national, international") || strings.Contains(prompt.Text, "{{") {
+		t.Errorf("summarizer prompt is not rendered:\n%s", prompt.Text)
+	}
+
+	// the date is not part of the version
+	data := newPromptData()
+	data.Date = "1 January 2000"
+	if other, _ := LoadPrompt(PromptSummarizer, data); other.Version != prompt.Version {
+		t.Errorf("version changed with the date: %s, %s", prompt.Version, other.Version)
+	}
+
+	// the taxonomy is
+	config.Taxonomy = append([]TaxonomyCategory{}, defaultTaxonomy...)
+	config.Taxonomy[0].Description = "Domestic affairs"
+	other, err := LoadPrompt(PromptSummarizer, newPromptData())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.Version == prompt.Version {
+		t.Errorf("version unchanged with the taxonomy: %s", prompt.Version)
+	}
+	if !strings.Contains(promptText(other.Source, other.Settings), "Domestic affairs") {
+		t.Errorf("settings are not shown with the prompt")
+	}
+}
+
+func TestTranslationPromptVersion(t *testing.T) {
+	db := testDB(t)
+	prompt, err := LoadPrompt(PromptTranslator, newPromptData())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []int64{1, 2} {
+		translation := ArticleTranslation{ArticleID: id, Language: LanguageEnglish, Title: "Floods", PromptVersion: prompt.Version, CreatedAt: time.Now()}
+		if err := InsertArticleTranslation(t.Context(), db, &translation); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var translations int
+	err = db.QueryRow(`SELECT COUNT(*) FROM article_translations WHERE prompt_version = ?`, prompt.Version).Scan(&translations)
+	if err != nil || translations != 2 {
+		t.Errorf("%d translations stored with version %s: %v", translations, prompt.Version, err)
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	directProxy                = "direct"
+	defaultHealthCheckURL      = "https://www.google.com/generate_204"
+	defaultHealthCheckInterval = 5 * time.Minute
+	proxyHealthCheckTimeout    = 10 * time.Second
+)
+
+type proxyKey struct{}
+
+type poolProxy struct {
+	url     *url.URL
+	healthy bool
+}
+
+// ProxyPool rotates through a list of proxies. Proxies failing with network
+// errors are marked unhealthy until a health check succeeds again.
+type ProxyPool struct {
+	mu      sync.Mutex
+	proxies []*poolProxy
+	current int
+}
+
+func NewProxyPool(proxies []string) (*ProxyPool, error) {
+	pool := &ProxyPool{}
+	for _, proxy := range proxies {
+		if proxy == directProxy {
+			pool.proxies = append(pool.proxies, &poolProxy{healthy: true})
+			continue
+		}
+		parsed, err := url.Parse(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy %q: %v", proxy, err)
+		}
+		switch parsed.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return nil, fmt.Errorf("unsupported proxy scheme %q", parsed.Scheme)
+		}
+		pool.proxies = append(pool.proxies, &poolProxy{url: parsed, healthy: true})
+	}
+	return pool, nil
+}
+
+// Current returns the proxy to use, skipping unhealthy ones. When every
+// proxy is unhealthy the current one is used anyway.
+func (p *ProxyPool) Current() *poolProxy {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i := range p.proxies {
+		proxy := p.proxies[(p.current+i)%len(p.proxies)]
+		if proxy.healthy {
+			p.current = (p.current + i) % len(p.proxies)
+			return proxy
+		}
+	}
+	return p.proxies[p.current]
+}
+
+// Rotate moves past proxy, if it is still the current one.
+func (p *ProxyPool) Rotate(proxy *poolProxy) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.proxies[p.current] == proxy {
+		p.current = (p.current + 1) % len(p.proxies)
+	}
+}
+
+func (p *ProxyPool) setHealthy(proxy *poolProxy, healthy bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	proxy.healthy = healthy
+}
+
+// CheckHealth requests checkURL through every proxy and updates their
+// health.
+func (p *ProxyPool) CheckHealth(ctx context.Context, base *http.Transport, checkURL string) {
+	var wg sync.WaitGroup
+	for _, proxy := range p.proxies {
+		if proxy.url == nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			transport := base.Clone()
+			transport.Proxy = http.ProxyURL(proxy.url)
+			defer transport.CloseIdleConnections()
+
+			ctx, cancel := context.WithTimeout(ctx, proxyHealthCheckTimeout)
+			defer cancel()
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, checkURL, nil)
+			if err != nil {
+				return
+			}
+			resp, err := transport.RoundTrip(req)
+			healthy := err == nil && resp.StatusCode < 400
+			if resp != nil {
+				resp.Body.Close()
+			}
+			if !healthy {
+				logger.Warn("Proxy unhealthy", "proxy", proxy.url.Redacted(), "error", err)
+			}
+			p.setHealthy(proxy, healthy)
+		}()
+	}
+	wg.Wait()
+}
+
+// ProxyTransport selects a proxy per publisher, rotating to the next one
+// when a publisher answers 403 or 429, and adds per-publisher headers and
+// cookies to every request.
+type ProxyTransport struct {
+	base       *http.Transport
+	pool       *ProxyPool
+	publishers map[string]*ProxyPool
+	config     NetworkConfig
+}
+
+// NewProxyTransport configures base to take its proxy from the request
+// context and wraps it. Health checks run every interval until ctx is done.
+func NewProxyTransport(ctx context.Context, base *http.Transport, config NetworkConfig) (*ProxyTransport, error) {
+	base.Proxy = func(req *http.Request) (*url.URL, error) {
+		if proxy, ok := req.Context().Value(proxyKey{}).(*poolProxy); ok {
+			return proxy.url, nil
+		}
+		return http.ProxyFromEnvironment(req)
+	}
+
+	t := &ProxyTransport{base: base, publishers: map[string]*ProxyPool{}, config: config}
+	var err error
+	if len(config.Proxies) > 0 {
+		if t.pool, err = NewProxyPool(config.Proxies); err != nil {
+			return nil, err
+		}
+	}
+	for publisher, publisherConfig := range config.Publishers {
+		if len(publisherConfig.Proxies) == 0 {
+			continue
+		}
+		if t.publishers[publisher], err = NewProxyPool(publisherConfig.Proxies); err != nil {
+			return nil, err
+		}
+	}
+
+	if t.pool != nil || len(t.publishers) > 0 {
+		go t.checkHealth(ctx)
+	}
+	return t, nil
+}
+
+func (t *ProxyTransport) checkHealth(ctx context.Context) {
+	checkURL := t.config.HealthCheckURL
+	if checkURL == "" {
+		checkURL = defaultHealthCheckURL
+	}
+	interval := t.config.HealthCheckInterval.Duration
+	if interval <= 0 {
+		interval = defaultHealthCheckInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if t.pool != nil {
+			t.pool.CheckHealth(ctx, t.base, checkURL)
+		}
+		for _, pool := range t.publishers {
+			pool.CheckHealth(ctx, t.base, checkURL)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (t *ProxyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	publisher := normalizeSource(req.URL.Hostname())
+	publisherConfig := t.config.Publishers[publisher]
+
+	pool := t.publishers[publisher]
+	if pool == nil {
+		pool = t.pool
+	}
+
+	ctx := req.Context()
+	var proxy *poolProxy
+	if pool != nil {
+		proxy = pool.Current()
+		ctx = context.WithValue(ctx, proxyKey{}, proxy)
+	}
+	req = req.Clone(ctx)
+	for name, value := range publisherConfig.Headers {
+		req.Header.Set(name, value)
+	}
+	for name, value := range publisherConfig.Cookies {
+		req.AddCookie(&http.Cookie{Name: name, Value: value})
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if pool == nil {
+		return resp, err
+	}
+	switch {
+	case err != nil && ctx.Err() == nil:
+		logger.Warn("Proxy request failed, rotating", "publisher", publisher, "error", err)
+		pool.setHealthy(proxy, false)
+		pool.Rotate(proxy)
+	case err == nil && (resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests):
+		logger.Warn("Publisher throttled proxy, rotating", "publisher", publisher, "status", resp.StatusCode)
+		pool.Rotate(proxy)
+	}
+	return resp, err
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// formatMetaTime keeps the publisher's zone offset; zero times are stored
+// as an empty string.
+func formatMetaTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// SaveRawArticles stores the extracted articles of a run together with the
+// metadata found on their pages, their page type and language.
+func SaveRawArticles(ctx context.Context, db *sql.DB, runID int64, articles []CrawlerResult) (err error) {
+	ctx, span := startSpan(ctx, "store.insert_raw_articles", attribute.Int("articles", len(articles)))
+	defer func() {
+		if err != nil {
+			err = &StoreError{Operation: fmt.Sprintf("inserting raw articles of run %d", runID), Err: err}
+		}
+		endSpan(span, err)
+	}()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, article := range articles {
+		entries := article.Entries
+		if entries == nil {
+			entries = []LiveBlogEntry{}
+		}
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO raw_articles (id, run_id, title, content, link, source, author, section, keywords, image_url, published_at, modified_at, page_type, entries, language, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, article.ID, runID, article.Title, article.Content, article.Link, article.Source, article.Author, article.Section,
+			strings.Join(article.Keywords, ","), article.ImageURL, formatMetaTime(article.PublishedAt), formatMetaTime(article.ModifiedAt),
+			lo.CoalesceOrEmpty(article.PageType, PageArticle), marshalColumn(entries), article.Language, time.Now().Format(createdAtLayout))
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type robotsRule struct {
+	allow   bool
+	length  int
+	pattern *regexp.Regexp
+}
+
+type robotsGroup struct {
+	agents     []string
+	rules      []robotsRule
+	crawlDelay time.Duration
+}
+
+// Robots is a parsed robots.txt. The zero value allows everything.
+type Robots struct {
+	groups      []*robotsGroup
+	disallowAll bool
+}
+
+// ParseRobots parses a robots.txt file following RFC 9309, plus the
+// non-standard but widely used Crawl-delay directive.
+func ParseRobots(r io.Reader) *Robots {
+	robots := &Robots{}
+	var current *robotsGroup
+	inAgents := false
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			// consecutive user-agent lines share the same group
+			if !inAgents {
+				current = &robotsGroup{}
+				robots.groups = append(robots.groups, current)
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+			inAgents = true
+		case "allow", "disallow":
+			inAgents = false
+			if current == nil || (key == "disallow" && value == "") {
+				continue
+			}
+			current.rules = append(current.rules, robotsRule{
+				allow:   key == "allow",
+				length:  len(value),
+				pattern: compileRobotsPattern(value),
+			})
+		case "crawl-delay":
+			inAgents = false
+			if current == nil {
+				continue
+			}
+			if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+				current.crawlDelay = time.Duration(seconds * float64(time.Second))
+			}
+		default:
+			inAgents = false
+		}
+	}
+	return robots
+}
+
+// productToken returns the product token of a User-Agent, the name before
+// the version, as "ngopibentar-crawler" in
+// "ngopibentar-crawler/1.0 (+https://...)".
+func productToken(agent string) string {
+	token, _, _ := strings.Cut(strings.TrimSpace(agent), " ")
+	token, _, _ = strings.Cut(token, "/")
+	return strings.ToLower(token)
+}
+
+// group returns the group whose user-agent equals the product token of
+// agent, ignoring case, falling back to the "*" group.
+func (r *Robots) group(agent string) *robotsGroup {
+	token := productToken(agent)
+	var fallback *robotsGroup
+	for _, group := range r.groups {
+		for _, name := range group.agents {
+			if name == "*" {
+				if fallback == nil {
+					fallback = group
+				}
+				continue
+			}
+			if name != "" && productToken(name) == token {
+				return group
+			}
+		}
+	}
+	return fallback
+}
+
+// Allowed reports whether agent may fetch path. The longest matching rule
+// wins and allow wins ties.
+func (r *Robots) Allowed(agent string, path string) bool {
+	if r.disallowAll {
+		return false
+	}
+	if path == "/robots.txt" {
+		return true
+	}
+	group := r.group(agent)
+	if group == nil {
+		return true
+	}
+
+	allowed := true
+	matched := -1
+	for _, rule := range group.rules {
+		if !rule.pattern.MatchString(path) {
+			continue
+		}
+		if rule.length > matched || (rule.length == matched && rule.allow) {
+			matched = rule.length
+			allowed = rule.allow
+		}
+	}
+	return allowed
+}
+
+func (r *Robots) CrawlDelay(agent string) time.Duration {
+	if group := r.group(agent); group != nil {
+		return group.crawlDelay
+	}
+	return 0
+}
+
+// compileRobotsPattern turns a robots.txt path pattern into a regular
+// expression supporting the "*" wildcard and the "$" end anchor.
+func compileRobotsPattern(pattern string) *regexp.Regexp {
+	anchored := strings.HasSuffix(pattern, "$")
+	expr := "^" + strings.ReplaceAll(regexp.QuoteMeta(strings.TrimSuffix(pattern, "$")), `\*`, ".*")
+	if anchored {
+		expr += "$"
+	}
+	return regexp.MustCompile(expr)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testRobots = `
+# comments are ignored
+User-agent: *
+Disallow: /search
+Allow: /search/about
+Disallow: /*.pdf$
+Crawl-delay: 2
+
+User-agent: ngopibentar-crawler
+User-agent: OtherBot
+Disallow: /private
+Crawl-delay: 0.5
+
+User-agent: crawler
+Disallow: /
+
+User-agent:
+Disallow: /
+`
+
+func TestRobotsAllowed(t *testing.T) {
+	robots := ParseRobots(strings.NewReader(testRobots))
+	agent := "ngopibentar-crawler/1.0 (+https://ngopibentar.com)"
+	tests := []struct {
+		agent string
+		path  string
+		want  bool
+	}{
+		// the crawler's own group, matched on the product token ignoring case
+		{agent, "/private/page", false},
+		{agent, "/search", true},
+		{"NgopiBentar-Crawler/2.0", "/private", false},
+		{"otherbot", "/private", false},
+		// substrings of the agent and empty user-agent lines do not match
+		{"someone/1.0 (+https://crawler.example)", "/", true},
+		{"my-crawler/1.0", "/", true},
+		// the "*" group
+		{"somebot/1.0", "/search?q=banjir", false},
+		{"somebot/1.0", "/search/about", true},
+		{"somebot/1.0", "/files/report.pdf", false},
+		{"somebot/1.0", "/files/report.pdf?download=1", true},
+		{"somebot/1.0", "/robots.txt", true},
+	}
+	for _, test := range tests {
+		if got := robots.Allowed(test.agent, test.path); got != test.want {
+			t.Errorf("Allowed(%q, %q) = %t, want %t", test.agent, test.path, got, test.want)
+		}
+	}
+}
+
+func TestRobotsCrawlDelay(t *testing.T) {
+	robots := ParseRobots(strings.NewReader(testRobots))
+	tests := []struct {
+		agent string
+		want  time.Duration
+	}{
+		{"ngopibentar-crawler/1.0", 500 * time.Millisecond},
+		{"somebot/1.0", 2 * time.Second},
+	}
+	for _, test := range tests {
+		if got := robots.CrawlDelay(test.agent); got != test.want {
+			t.Errorf("CrawlDelay(%q) = %s, want %s", test.agent, got, test.want)
+		}
+	}
+	if got := (&Robots{}).CrawlDelay("somebot"); got != 0 {
+		t.Errorf("empty robots.txt has a crawl delay of %s", got)
+	}
+}
+
+func TestPoliteTransportRobotsRedirects(t *testing.T) {
+	tests := []struct {
+		redirects int
+		want      bool
+	}{
+		{1, false},
+		{maxRobotsRedirects, false},
+		// past the limit robots.txt is unavailable, which allows everything
+		{maxRobotsRedirects + 1, true},
+	}
+	for _, test := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/robots.txt" {
+				http.Redirect(w, r, "/robots/1", http.StatusMovedPermanently)
+				return
+			}
+			if hop, ok := strings.CutPrefix(r.URL.Path, "/robots/"); ok {
+				if n, _ := strconv.Atoi(hop); n < test.redirects {
+					http.Redirect(w, r, fmt.Sprintf("/robots/%d", n+1), http.StatusFound)
+					return
+				}
+				fmt.Fprint(w, "User-agent: *\nDisallow: /private\n")
+				return
+			}
+			fmt.Fprint(w, "ok")
+		}))
+
+		transport := NewPoliteTransport(http.DefaultTransport, 0, 0, true)
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/private", nil)
+		req.Header.Set("User-Agent", "ngopibentar-crawler/1.0")
+		resp, err := transport.RoundTrip(req)
+		if resp != nil {
+			resp.Body.Close()
+		}
+		if got := !errors.Is(err, ErrDisallowedByRobots); got != test.want {
+			t.Errorf("%d redirects: allowed = %t, want %t (error %v)", test.redirects, got, test.want, err)
+		}
+		server.Close()
+	}
+}
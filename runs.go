@@ -0,0 +1,476 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/samber/lo"
+	"google.golang.org/genai"
+)
+
+const (
+	RunStatusRunning = "running"
+	RunStatusSuccess = "success"
+	RunStatusFailed  = "failed"
+)
+
+type TargetReport struct {
+	Skipped   string `json:"skipped,omitempty"`
+	Items     int    `json:"items"`
+	Extracted int    `json:"extracted"`
+	Dropped   int    `json:"dropped"`
+	Error     string `json:"error,omitempty"`
+}
+
+type SummarizationFailure struct {
+	Links  []string `json:"links"`
+	Reason string   `json:"reason"`
+}
+
+type TokenUsage struct {
+	Prompt     int64 `json:"prompt"`
+	Candidates int64 `json:"candidates"`
+	Total      int64 `json:"total"`
+}
+
+// RunReport collects what happened during a crawl run. It travels through
+// the pipeline in the context and is safe for concurrent use; all methods
+// are no-ops on a nil report.
+type RunReport struct {
+	mu sync.Mutex
+
+	ID                    int64
+	StartedAt             time.Time
+	EndedAt               time.Time
+	Status                string
+	Error                 string
+	Targets               map[string]*TargetReport
+	ExtractionFailures    []ErrorRecord
+	Groups                int
+	SummarizationFailures []SummarizationFailure
+	InsertedArticleIDs    []int64
+	AiModel               string
+	Tokens                TokenUsage
+	Publishers            map[string]*PublisherStats
+	Errors                []ErrorRecord
+}
+
+type runReportKey struct{}
+
+func NewRunReport(id int64) *RunReport {
+	return &RunReport{
+		ID:        id,
+		StartedAt: time.Now(),
+		Status:    RunStatusRunning,
+		Targets:   map[string]*TargetReport{},
+	}
+}
+
+func withRunReport(ctx context.Context, report *RunReport) context.Context {
+	return context.WithValue(withRunID(ctx, report.ID), runReportKey{}, report)
+}
+
+func reportFromContext(ctx context.Context) *RunReport {
+	report, _ := ctx.Value(runReportKey{}).(*RunReport)
+	return report
+}
+
+func (r *RunReport) target(url string) *TargetReport {
+	target, ok := r.Targets[url]
+	if !ok {
+		target = &TargetReport{}
+		r.Targets[url] = target
+	}
+	return target
+}
+
+func (r *RunReport) TargetFetched(url string, items int, err error) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	target := r.target(url)
+	target.Items = items
+	if err != nil {
+		target.Error = err.Error()
+	}
+}
+
+// TargetSkipped records a feed that was not crawled because it did not
+// change since the previous run.
+func (r *RunReport) TargetSkipped(url string, reason string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.target(url).Skipped = reason
+}
+
+func (r *RunReport) ArticleExtracted(target string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.target(target).Extracted++
+}
+
+func (r *RunReport) ArticleDropped(target string, err error) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.target(target).Dropped++
+	r.ExtractionFailures = append(r.ExtractionFailures, NewErrorRecord(err))
+}
+
+// RecordError adds a failure outside of article extraction to the report.
+func (r *RunReport) RecordError(err error) {
+	if r == nil || err == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Errors = append(r.Errors, NewErrorRecord(err))
+}
+
+func (r *RunReport) Grouped(groups int) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Groups = groups
+}
+
+func (r *RunReport) SummarizationFailed(links []string, err error) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Errors = append(r.Errors, NewErrorRecord(err))
+	r.SummarizationFailures = append(r.SummarizationFailures, SummarizationFailure{
+		Links:  links,
+		Reason: err.Error(),
+	})
+}
+
+// GroupsSkipped records the groups left unsummarized when the run deadline
+// was reached.
+func (r *RunReport) GroupsSkipped(links [][]string, reason string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, group := range links {
+		r.SummarizationFailures = append(r.SummarizationFailures, SummarizationFailure{
+			Links:  group,
+			Reason: reason,
+		})
+	}
+}
+
+func (r *RunReport) ArticleInserted(id int64, aiModel string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.InsertedArticleIDs = append(r.InsertedArticleIDs, id)
+	r.AiModel = aiModel
+}
+
+func (r *RunReport) AddTokens(usage *genai.GenerateContentResponseUsageMetadata) {
+	if r == nil || usage == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Tokens.Prompt += int64(usage.PromptTokenCount)
+	r.Tokens.Candidates += int64(usage.CandidatesTokenCount)
+	r.Tokens.Total += int64(usage.TotalTokenCount)
+}
+
+func (r *RunReport) Finish(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.EndedAt = time.Now()
+	r.Status = RunStatusSuccess
+	if err != nil {
+		r.Status = RunStatusFailed
+		r.Error = err.Error()
+	}
+}
+
+func marshalColumn(value any) string {
+	output, err := json.Marshal(value)
+	if err != nil {
+		return "null"
+	}
+	return string(output)
+}
+
+func SaveRunReport(db *sql.DB, report *RunReport) error {
+	report.mu.Lock()
+	defer report.mu.Unlock()
+
+	ids := lo.Map(report.InsertedArticleIDs, func(id int64, _ int) string {
+		return strconv.FormatInt(id, 10)
+	})
+	_, err := db.Exec(`
+		INSERT INTO crawl_runs (id, started_at, ended_at, status, error, targets, extraction_failures, groups, summarization_failures, inserted_article_ids, ai_model, prompt_tokens, candidates_tokens, total_tokens, errors)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, report.ID, report.StartedAt.Format(createdAtLayout), report.EndedAt.Format(createdAtLayout), report.Status, report.Error,
+		marshalColumn(report.Targets), marshalColumn(report.ExtractionFailures), report.Groups, marshalColumn(report.SummarizationFailures),
+		strings.Join(ids, ","), report.AiModel, report.Tokens.Prompt, report.Tokens.Candidates, report.Tokens.Total, marshalColumn(report.Errors))
+	return err
+}
+
+const runColumns = `id, started_at, ended_at, status, error, targets, extraction_failures, groups, summarization_failures, inserted_article_ids, ai_model, prompt_tokens, candidates_tokens, total_tokens, errors`
+
+func scanRunReport(rows *sql.Rows) (*RunReport, error) {
+	report := &RunReport{}
+	var startedAt, endedAt, targets, extractionFailures, summarizationFailures, insertedIDs, errors string
+	err := rows.Scan(&report.ID, &startedAt, &endedAt, &report.Status, &report.Error, &targets, &extractionFailures, &report.Groups,
+		&summarizationFailures, &insertedIDs, &report.AiModel, &report.Tokens.Prompt, &report.Tokens.Candidates, &report.Tokens.Total, &errors)
+	if err != nil {
+		return nil, fmt.Errorf("error scanning crawl run: %v", err)
+	}
+	report.StartedAt, _ = time.ParseInLocation(createdAtLayout, startedAt, time.Local)
+	report.EndedAt, _ = time.ParseInLocation(createdAtLayout, endedAt, time.Local)
+	json.Unmarshal([]byte(targets), &report.Targets)
+	json.Unmarshal([]byte(extractionFailures), &report.ExtractionFailures)
+	json.Unmarshal([]byte(summarizationFailures), &report.SummarizationFailures)
+	json.Unmarshal([]byte(errors), &report.Errors)
+	for _, id := range splitList(insertedIDs) {
+		parsed, err := strconv.ParseInt(id, 10, 64)
+		if err == nil {
+			report.InsertedArticleIDs = append(report.InsertedArticleIDs, parsed)
+		}
+	}
+	if report.Targets == nil {
+		report.Targets = map[string]*TargetReport{}
+	}
+	return report, nil
+}
+
+func queryRunReports(db *sql.DB, query string, args ...any) ([]*RunReport, error) {
+	rows, err := db.Query(`SELECT `+runColumns+` FROM crawl_runs `+query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying crawl runs: %v", err)
+	}
+	defer rows.Close()
+
+	var reports []*RunReport
+	for rows.Next() {
+		report, err := scanRunReport(rows)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, report)
+	}
+	return reports, rows.Err()
+}
+
+func ListRunReports(db *sql.DB, limit int) ([]*RunReport, error) {
+	return queryRunReports(db, `ORDER BY id DESC LIMIT ?`, limit)
+}
+
+func GetRunReport(db *sql.DB, id int64) (*RunReport, error) {
+	reports, err := queryRunReports(db, `WHERE id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(reports) == 0 {
+		return nil, fmt.Errorf("crawl run %d not found", id)
+	}
+	return reports[0], nil
+}
+
+func (r *RunReport) totalItems() (items int, extracted int, dropped int) {
+	for _, target := range r.Targets {
+		items += target.Items
+		extracted += target.Extracted
+		dropped += target.Dropped
+	}
+	return items, extracted, dropped
+}
+
+func printRunReport(report *RunReport) {
+	items, extracted, dropped := report.totalItems()
+	fmt.Printf("Run %d (%s)\n", report.ID, report.Status)
+	fmt.Printf("  started:  %s\n", report.StartedAt.Format(createdAtLayout))
+	fmt.Printf("  ended:    %s (%s)\n", report.EndedAt.Format(createdAtLayout), report.EndedAt.Sub(report.StartedAt))
+	if report.Error != "" {
+		fmt.Printf("  error:    %s\n", report.Error)
+	}
+	fmt.Printf("  model:    %s\n", report.AiModel)
+	fmt.Printf("  tokens:   %d prompt, %d candidates, %d total\n", report.Tokens.Prompt, report.Tokens.Candidates, report.Tokens.Total)
+	fmt.Printf("  items:    %d fetched, %d extracted, %d dropped\n", items, extracted, dropped)
+	fmt.Printf("  groups:   %d (%d failed)\n", report.Groups, len(report.SummarizationFailures))
+	fmt.Printf("  inserted: %d\n", len(report.InsertedArticleIDs))
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "\nTARGET\tITEMS\tEXTRACTED\tDROPPED\tSKIPPED\tERROR")
+	keys := lo.Keys(report.Targets)
+	sort.Strings(keys)
+	for _, url := range keys {
+		target := report.Targets[url]
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%s\t%s\n", url, target.Items, target.Extracted, target.Dropped, target.Skipped, target.Error)
+	}
+	w.Flush()
+
+	if len(report.ExtractionFailures) > 0 {
+		fmt.Println("\nExtraction failures:")
+		for _, failure := range report.ExtractionFailures {
+			fmt.Printf("  [%s] %s %s: %s\n", failure.Publisher, failure.Type, failure.URL, failure.Message)
+		}
+	}
+	if len(report.Errors) > 0 {
+		fmt.Println("\nErrors by stage:")
+		counts := lo.CountValuesBy(report.Errors, func(record ErrorRecord) string {
+			return string(record.Stage) + " " + record.Type
+		})
+		keys := lo.Keys(counts)
+		sort.Strings(keys)
+		for _, key := range keys {
+			fmt.Printf("  %-40s %d\n", key, counts[key])
+		}
+	}
+	if len(report.SummarizationFailures) > 0 {
+		fmt.Println("\nSummarization failures:")
+		for _, failure := range report.SummarizationFailures {
+			fmt.Printf("  %s: %s\n", strings.Join(failure.Links, ", "), failure.Reason)
+		}
+	}
+}
+
+func printRunDiff(a *RunReport, b *RunReport) {
+	diff := func(label string, from int64, to int64) {
+		fmt.Printf("  %-24s %8d -> %-8d (%+d)\n", label, from, to, to-from)
+	}
+	aItems, aExtracted, aDropped := a.totalItems()
+	bItems, bExtracted, bDropped := b.totalItems()
+
+	fmt.Printf("Run %d -> %d\n", a.ID, b.ID)
+	diff("items fetched", int64(aItems), int64(bItems))
+	diff("items extracted", int64(aExtracted), int64(bExtracted))
+	diff("items dropped", int64(aDropped), int64(bDropped))
+	diff("extraction failures", int64(len(a.ExtractionFailures)), int64(len(b.ExtractionFailures)))
+	diff("groups", int64(a.Groups), int64(b.Groups))
+	diff("summarization failures", int64(len(a.SummarizationFailures)), int64(len(b.SummarizationFailures)))
+	diff("articles inserted", int64(len(a.InsertedArticleIDs)), int64(len(b.InsertedArticleIDs)))
+	diff("total tokens", a.Tokens.Total, b.Tokens.Total)
+	diff("duration (s)", int64(a.EndedAt.Sub(a.StartedAt).Seconds()), int64(b.EndedAt.Sub(b.StartedAt).Seconds()))
+	if a.AiModel != b.AiModel {
+		fmt.Printf("  %-24s %s -> %s\n", "model", a.AiModel, b.AiModel)
+	}
+
+	fmt.Println("\nPer target (extracted):")
+	keys := lo.Uniq(append(lo.Keys(a.Targets), lo.Keys(b.Targets)...))
+	sort.Strings(keys)
+	for _, url := range keys {
+		var from, to TargetReport
+		if target, ok := a.Targets[url]; ok {
+			from = *target
+		}
+		if target, ok := b.Targets[url]; ok {
+			to = *target
+		}
+		diff(url, int64(from.Extracted), int64(to.Extracted))
+	}
+}
+
+func runRuns(db *sql.DB, args []string) error {
+	subcommand := "list"
+	if len(args) > 0 {
+		subcommand, args = args[0], args[1:]
+	}
+
+	flags := flag.NewFlagSet("runs "+subcommand, flag.ExitOnError)
+	switch subcommand {
+	case "list":
+		limit := flags.Int("limit", 10, "number of runs to show")
+		flags.Parse(args)
+		reports, err := ListRunReports(db, *limit)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tSTARTED AT\tDURATION\tSTATUS\tEXTRACTED\tDROPPED\tGROUPS\tFAILED\tINSERTED\tTOKENS")
+		for _, report := range reports {
+			_, extracted, dropped := report.totalItems()
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%d\t%d\t%d\t%d\t%d\t%d\n", report.ID, report.StartedAt.Format(createdAtLayout),
+				report.EndedAt.Sub(report.StartedAt).Round(time.Second), report.Status, extracted, dropped, report.Groups,
+				len(report.SummarizationFailures), len(report.InsertedArticleIDs), report.Tokens.Total)
+		}
+		w.Flush()
+	case "show":
+		id := flags.Int64("id", 0, "run ID, defaults to the latest run")
+		flags.Parse(args)
+		var report *RunReport
+		if *id == 0 {
+			reports, err := ListRunReports(db, 1)
+			if err != nil {
+				return err
+			}
+			if len(reports) == 0 {
+				return fmt.Errorf("no crawl runs recorded")
+			}
+			report = reports[0]
+		} else {
+			var err error
+			if report, err = GetRunReport(db, *id); err != nil {
+				return err
+			}
+		}
+		printRunReport(report)
+	case "diff":
+		from := flags.Int64("from", 0, "older run ID, defaults to the second latest run")
+		to := flags.Int64("to", 0, "newer run ID, defaults to the latest run")
+		flags.Parse(args)
+
+		var a, b *RunReport
+		if *from == 0 || *to == 0 {
+			reports, err := ListRunReports(db, 2)
+			if err != nil {
+				return err
+			}
+			if len(reports) < 2 {
+				return fmt.Errorf("need at least two crawl runs to diff")
+			}
+			a, b = reports[1], reports[0]
+		}
+		var err error
+		if *from != 0 {
+			if a, err = GetRunReport(db, *from); err != nil {
+				return err
+			}
+		}
+		if *to != 0 {
+			if b, err = GetRunReport(db, *to); err != nil {
+				return err
+			}
+		}
+		printRunDiff(a, b)
+	default:
+		return fmt.Errorf("unknown runs command %q", subcommand)
+	}
+	return nil
+}
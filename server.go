@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/samber/lo"
+)
+
+const defaultFeedLimit = 50
+
+func newServeMux(db *sql.DB) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /feeds/{file}", feedHandler(db, ""))
+	mux.HandleFunc("GET /feeds/{category}/{file}", feedHandler(db, ""))
+	// the other editions are served below their language prefix as well,
+	// matching the self links of their feeds
+	for language := range editionLanguageNames {
+		mux.HandleFunc("GET /"+language+"/feeds/{file}", feedHandler(db, language))
+		mux.HandleFunc("GET /"+language+"/feeds/{category}/{file}", feedHandler(db, language))
+	}
+	mux.Handle("GET /images/", http.StripPrefix("/images/", http.FileServer(http.Dir(localImageDir()))))
+	mux.Handle("GET /metrics", promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{}))
+	return mux
+}
+
+func feedHandler(db *sql.DB, language string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		format, ok := feedFormatFromFileName(r.PathValue("file"))
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		category := r.PathValue("category")
+		if category != "" && !lo.Contains(categories(), category) {
+			http.NotFound(w, r)
+			return
+		}
+		language := language
+		if value := r.URL.Query().Get("lang"); language == "" && value != "" {
+			if !validEdition(value) {
+				http.Error(w, "invalid lang", http.StatusBadRequest)
+				return
+			}
+			language = value
+		}
+		limit := defaultFeedLimit
+		if value := r.URL.Query().Get("limit"); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed <= 0 {
+				http.Error(w, "invalid limit", http.StatusBadRequest)
+				return
+			}
+			limit = min(parsed, 500)
+		}
+
+		articles, err := ListArticles(db, ArticleQuery{Category: category, Limit: limit, Language: language})
+		if err != nil {
+			logger.Error("Error listing articles", "error", err)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		output, err := RenderFeed(format, language, category, articles)
+		if err != nil {
+			logger.Error("Error rendering feed", "format", format, "error", err)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", feedContentTypes[format])
+		w.Write(output)
+	}
+}
+
+// listenAndServe binds addr before serving in the background so that a bad
+// address fails at once. Errors of the running server are sent to errs.
+func listenAndServe(addr string, handler http.Handler) (*http.Server, <-chan error, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error listening on %s: %v", addr, err)
+	}
+	server := &http.Server{Addr: addr, Handler: handler}
+	errs := make(chan error, 1)
+	go func() {
+		logger.Info("Listening", "addr", listener.Addr().String())
+		errs <- server.Serve(listener)
+	}()
+	return server, errs, nil
+}
+
+func runServe(ctx context.Context, db *sql.DB, args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := flags.String("addr", ":8080", "address to listen on")
+	flags.Parse(args)
+
+	server, errs, err := listenAndServe(*addr, newServeMux(db))
+	if err != nil {
+		return err
+	}
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+		logger.Info("Shutting down")
+		return server.Shutdown(context.Background())
+	}
+}
+
+// runDaemon serves the HTTP endpoints and runs the crawler on an interval.
+func runDaemon(ctx context.Context, db *sql.DB, args []string) error {
+	flags := flag.NewFlagSet("daemon", flag.ExitOnError)
+	addr := flags.String("addr", ":8080", "address to listen on")
+	interval := flags.Duration("interval", 30*time.Minute, "time between crawl runs")
+	flags.Parse(args)
+
+	server, errs, err := listenAndServe(*addr, newServeMux(db))
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for {
+		if err := runCrawl(ctx, db); err != nil {
+			logger.Error("Error running crawler", "error", err)
+		}
+
+		select {
+		case err := <-errs:
+			return err
+		case <-ctx.Done():
+			logger.Info("Shutting down")
+			return server.Shutdown(context.Background())
+		case <-ticker.C:
+		}
+	}
+}
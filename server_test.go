@@ -0,0 +1,28 @@
+package main
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestRunDaemonAddressInUse(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- runDaemon(t.Context(), testDB(t), []string{"-addr", listener.Addr().String()})
+	}()
+	select {
+	case err := <-errs:
+		if err == nil {
+			t.Errorf("daemon started on an address in use")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("bind failure not reported before the first crawl")
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/samber/lo"
+)
+
+const (
+	QualityParagraphs  = "paragraphs"
+	QualityExcerpt     = "excerpt"
+	QualityLength      = "length"
+	QualityAttribution = "attribution"
+	QualitySources     = "sources"
+	QualityCategory    = "category"
+)
+
+const (
+	defaultSummaryMinParagraphs = 3
+	defaultSummaryMaxParagraphs = 5
+	// defaultSummaryMaxLengthRatio bounds long_content against the content
+	// of the news it was written from.
+	defaultSummaryMaxLengthRatio = 1.0
+	defaultSummaryRetries        = 1
+)
+
+// attributionPhrases are the ways the summarizer prompt asks media sources
+// to be credited. They only count when followed by a name, as words such as
+// "menurut" are common in any news text.
+var attributionPhrases = []string{
+	"dilansir dari", "dilansir", "menurut laporan", "menurut", "seperti diberitakan", "diberitakan",
+	"seperti dilaporkan", "dilaporkan", "melaporkan", "dikutip dari", "mengutip", "berdasarkan laporan",
+}
+
+// publisherNames are the ways a publisher is written in a story. They are
+// matched case-sensitively after an attribution phrase, since some are also
+// common words: "antara" (between), "detik" (second), "tempo" (pace).
+var publisherNames = map[string][]string{
+	"Kompas":   {"Kompas"},
+	"CNN":      {"CNN"},
+	"Liputan6": {"Liputan6", "Liputan 6"},
+	"Kumparan": {"Kumparan", "kumparan"},
+	"CNBC":     {"CNBC"},
+	"Detik":    {"Detik", "detikcom", "detik.com"},
+	"Tempo":    {"Tempo", "TEMPO"},
+	"Antara":   {"Antara", "ANTARA"},
+	"Tribun":   {"Tribun"},
+}
+
+// attributionPattern matches an attribution phrase followed by name, a
+// regular expression, as in "Dilansir dari laman Kompas.com".
+func attributionPattern(name string) *regexp.Regexp {
+	return regexp.MustCompile(`(?i:\b(?:` + strings.Join(attributionPhrases, "|") + `)(?:\s+(?:laman|situs|media))?)\s+(?:` + name + `)`)
+}
+
+var (
+	// anyAttribution accepts any capitalized name, for publishers that are
+	// not known.
+	anyAttribution       = attributionPattern(`\p{Lu}`)
+	publisherAttribution = lo.MapValues(publisherNames, func(names []string, _ string) *regexp.Regexp {
+		return attributionPattern(strings.Join(lo.Map(names, func(name string, _ int) string {
+			return regexp.QuoteMeta(name)
+		}), "|"))
+	})
+)
+
+// summaryParagraphs returns the number of paragraphs the long_content of a
+// story may have, shared by the summarizer prompt and ValidateSummary.
+func summaryParagraphs() (int, int) {
+	return envInt("SUMMARY_MIN_PARAGRAPHS", defaultSummaryMinParagraphs), envInt("SUMMARY_MAX_PARAGRAPHS", defaultSummaryMaxParagraphs)
+}
+
+// QualityIssue is a summarizer output that breaks one of the rules of the
+// prompt.
+type QualityIssue struct {
+	Check  string `json:"check"`
+	Detail string `json:"detail"`
+}
+
+func paragraphs(text string) []string {
+	return lo.Filter(paragraphSeparator.Split(strings.TrimSpace(text), -1), func(paragraph string, _ int) bool {
+		return strings.TrimSpace(paragraph) != ""
+	})
+}
+
+// ValidateSummary checks a summarized story against the news items it was
+// written from.
+func ValidateSummary(article AIResponse, inputs []Summarizer) []QualityIssue {
+	var issues []QualityIssue
+	add := func(check string, format string, args ...any) {
+		issues = append(issues, QualityIssue{Check: check, Detail: fmt.Sprintf(format, args...)})
+	}
+
+	minParagraphs, maxParagraphs := summaryParagraphs()
+	if count := len(paragraphs(article.LongContent)); count < minParagraphs || count > maxParagraphs {
+		add(QualityParagraphs, "long_content has %d paragraphs, expected %d-%d", count, minParagraphs, maxParagraphs)
+	}
+	if count := len(paragraphs(article.Excerpt)); count != 1 {
+		add(QualityExcerpt, "excerpt has %d paragraphs, expected 1", count)
+	}
+
+	if !lo.Contains(categories(), article.Category) {
+		add(QualityCategory, "unknown category %q", article.Category)
+	} else if article.Subcategory != "" && !validSubcategory(article.Category, article.Subcategory) {
+		add(QualityCategory, "subcategory %q is not part of %q", article.Subcategory, article.Category)
+	}
+
+	links := lo.Map(inputs, func(input Summarizer, _ int) string {
+		return input.Link
+	})
+	if len(article.Sources) == 0 {
+		add(QualitySources, "no sources")
+	}
+	if unknown := lo.Without(article.Sources, links...); len(unknown) > 0 {
+		add(QualitySources, "sources not in the input: %s", strings.Join(unknown, ", "))
+	}
+
+	// the length is compared with the news the story cites, or with the
+	// whole group when it cites none of them
+	cited := lo.Filter(inputs, func(input Summarizer, _ int) bool {
+		return lo.Contains(article.Sources, input.Link)
+	})
+	if len(cited) == 0 {
+		cited = inputs
+	}
+	original := lo.SumBy(cited, func(input Summarizer) int {
+		return utf8.RuneCountInString(input.Content)
+	})
+	ratio := envFloat("SUMMARY_MAX_LENGTH_RATIO", defaultSummaryMaxLengthRatio)
+	if length := utf8.RuneCountInString(article.LongContent); original > 0 && float64(length) > ratio*float64(original) {
+		add(QualityLength, "long_content has %d characters, the sources %d", length, original)
+	}
+
+	if !anyAttribution.MatchString(article.LongContent) {
+		add(QualityAttribution, "no attribution such as \"Dilansir dari [Media Name]\"")
+	}
+	for _, publisher := range lo.Uniq(lo.Map(cited, func(input Summarizer, _ int) string {
+		return normalizeSource(input.Link)
+	})) {
+		if pattern, ok := publisherAttribution[publisher]; ok && !pattern.MatchString(article.LongContent) {
+			add(QualityAttribution, "%s is cited but not credited", publisher)
+		}
+	}
+
+	return issues
+}
+
+// validateSummaries checks every story of a response, returning the issues
+// per story and their total.
+func validateSummaries(response *SummarizerResponse, inputs []Summarizer) ([][]QualityIssue, int) {
+	total := 0
+	issues := make([][]QualityIssue, len(response.Articles))
+	for i, article := range response.Articles {
+		issues[i] = ValidateSummary(article, inputs)
+		total += len(issues[i])
+	}
+	return issues, total
+}
+
+// SummarizeChecked summarizes payload and validates the stories, asking
+// again up to SUMMARY_RETRIES times when some fail the checks. The attempt
+// with the fewest issues is returned together with its remaining issues,
+// which are stored as quality flags.
+func SummarizeChecked(ctx context.Context, payload []Summarizer) (*SummarizerResponse, [][]QualityIssue, error) {
+	retries := envInt("SUMMARY_RETRIES", defaultSummaryRetries)
+	var best *SummarizerResponse
+	var bestIssues [][]QualityIssue
+	bestTotal := 0
+	for attempt := 0; attempt <= retries; attempt++ {
+		summarizeCtx, cancelSummarize := context.WithTimeout(ctx, envDuration("SUMMARIZE_TIMEOUT", 2*time.Minute))
+		response, err := Summarize(summarizeCtx, payload)
+		cancelSummarize()
+		if err != nil {
+			// a failed retry still leaves the earlier attempt
+			if best != nil {
+				logger.Warn("Error retrying summary, keeping the previous attempt", "error", err)
+				break
+			}
+			return nil, nil, err
+		}
+
+		issues, total := validateSummaries(response, payload)
+		for _, articleIssues := range issues {
+			for _, issue := range articleIssues {
+				summaryQualityIssues.WithLabelValues(issue.Check).Inc()
+			}
+		}
+		if best == nil || total < bestTotal {
+			best, bestIssues, bestTotal = response, issues, total
+		}
+		if total == 0 {
+			break
+		}
+		logger.Warn("Summary failed quality checks", "attempt", attempt+1, "issues", issues)
+	}
+	return best, bestIssues, nil
+}
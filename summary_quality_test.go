@@ -0,0 +1,83 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/samber/lo"
+)
+
+func TestValidateSummary(t *testing.T) {
+	inputs := []Summarizer{
+		{Link: "https://www.antaranews.com/berita/1/banjir-bekasi", Content: strings.Repeat("isi berita banjir. ", 200)},
+		{Link: "https://news.detik.com/berita/d-2/banjir-bekasi", Content: strings.Repeat("isi berita banjir. ", 200)},
+	}
+	sources := []string{inputs[0].Link, inputs[1].Link}
+	long := func(paragraphs ...string) string {
+		return strings.Join(paragraphs, "\n\n")
+	}
+	credited := "Dilansir dari Antara, banjir merendam ratusan rumah. Menurut detikcom, warga mengungsi."
+
+	tests := []struct {
+		name    string
+		article AIResponse
+		want    []string
+	}{
+		{
+			name: "valid",
+			article: AIResponse{Excerpt: "Banjir merendam Bekasi.", Category: "national", Sources: sources,
+				LongContent: long(credited, "Paragraf kedua.", "Paragraf ketiga.")},
+		},
+		{
+			name: "too few paragraphs",
+			article: AIResponse{Excerpt: "Banjir merendam Bekasi.", Category: "national", Sources: sources,
+				LongContent: long(credited, "Paragraf kedua.")},
+			want: []string{QualityParagraphs},
+		},
+		{
+			name: "too many paragraphs",
+			article: AIResponse{Excerpt: "Banjir merendam Bekasi.", Category: "national", Sources: sources,
+				LongContent: long(credited, "Dua.", "Tiga.", "Empat.", "Lima.", "Enam.")},
+			want: []string{QualityParagraphs},
+		},
+		{
+			name: "excerpt and category",
+			article: AIResponse{Excerpt: "Satu.\n\nDua.", Category: "weather", Sources: sources,
+				LongContent: long(credited, "Paragraf kedua.", "Paragraf ketiga.")},
+			want: []string{QualityExcerpt, QualityCategory},
+		},
+		{
+			name: "made up source",
+			article: AIResponse{Excerpt: "Banjir merendam Bekasi.", Category: "national", Sources: append(sources, "https://example.com/x"),
+				LongContent: long(credited, "Paragraf kedua.", "Paragraf ketiga.")},
+			want: []string{QualitySources},
+		},
+		{
+			name: "publisher names used as common words",
+			article: AIResponse{Excerpt: "Banjir merendam Bekasi.", Category: "national", Sources: sources,
+				LongContent: long("Menurut warga, banjir terjadi antara pukul 02.00 dan 04.00, detik-detik air naik.", "Paragraf kedua.", "Paragraf ketiga.")},
+			want: []string{QualityAttribution, QualityAttribution, QualityAttribution},
+		},
+		{
+			name: "one publisher not credited",
+			article: AIResponse{Excerpt: "Banjir merendam Bekasi.", Category: "national", Sources: sources,
+				LongContent: long("Seperti diberitakan ANTARA, banjir merendam ratusan rumah.", "Paragraf kedua.", "Paragraf ketiga.")},
+			want: []string{QualityAttribution},
+		},
+		{
+			name: "too long",
+			article: AIResponse{Excerpt: "Banjir merendam Bekasi.", Category: "national", Sources: sources[:1],
+				LongContent: long(credited, "Paragraf kedua.", strings.Repeat("panjang ", 1000))},
+			want: []string{QualityLength},
+		},
+	}
+	for _, test := range tests {
+		issues := ValidateSummary(test.article, inputs)
+		got := lo.Map(issues, func(issue QualityIssue, _ int) string {
+			return issue.Check
+		})
+		if strings.Join(got, ",") != strings.Join(test.want, ",") {
+			t.Errorf("%s: issues %v, want %v", test.name, issues, test.want)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/samber/lo"
+)
+
+// TaxonomyCategory is a category stories are filed under.
+type TaxonomyCategory struct {
+	Slug string `json:"slug"`
+	// Labels are the display names keyed by edition language; the slug is
+	// shown when a language has none.
+	Labels map[string]string `json:"labels"`
+	// Description tells the summarizer what belongs in the category.
+	Description   string             `json:"description"`
+	Subcategories []TaxonomyCategory `json:"subcategories,omitempty"`
+	// Aliases are former slugs, used by the recategorize command to move
+	// stored articles after the taxonomy changes.
+	Aliases []string `json:"aliases,omitempty"`
+}
+
+// defaultTaxonomy is used when the config has no taxonomy.
+var defaultTaxonomy = []TaxonomyCategory{
+	{
+		Slug:        "national",
+		Labels:      map[string]string{LanguageIndonesian: "Nasional", LanguageEnglish: "National"},
+		Description: "Domestic Indonesian affairs that are not primarily political: public services, law enforcement, disasters, regional and social issues",
+	},
+	{
+		Slug:        "international",
+		Labels:      map[string]string{LanguageIndonesian: "Internasional", LanguageEnglish: "International"},
+		Description: "Events outside Indonesia and Indonesia's foreign relations",
+	},
+	{
+		Slug:        "entertainment",
+		Labels:      map[string]string{LanguageIndonesian: "Hiburan", LanguageEnglish: "Entertainment"},
+		Description: "Celebrities, film, music, television and lifestyle",
+	},
+	{
+		Slug:        "sports",
+		Labels:      map[string]string{LanguageIndonesian: "Olahraga", LanguageEnglish: "Sports"},
+		Description: "Competitions, athletes, clubs and sports organizations",
+	},
+	{
+		Slug:        "technology",
+		Labels:      map[string]string{LanguageIndonesian: "Teknologi", LanguageEnglish: "Technology"},
+		Description: "Gadgets, internet, telecommunications, science and tech companies' products",
+	},
+	{
+		Slug:        "business",
+		Labels:      map[string]string{LanguageIndonesian: "Bisnis", LanguageEnglish: "Business"},
+		Description: "Economy, markets, companies, finance and trade",
+	},
+	{
+		Slug:        "politics",
+		Labels:      map[string]string{LanguageIndonesian: "Politik", LanguageEnglish: "Politics"},
+		Description: "Government, parliament, parties, elections and public policy debates",
+	},
+}
+
+// taxonomy returns the configured taxonomy or the default one.
+func taxonomy() []TaxonomyCategory {
+	if len(config.Taxonomy) > 0 {
+		return config.Taxonomy
+	}
+	return defaultTaxonomy
+}
+
+// categories returns the slugs of the top level categories, the values the
+// summarizer may pick and the feeds and exports are split by.
+func categories() []string {
+	return lo.Map(taxonomy(), func(category TaxonomyCategory, _ int) string {
+		return category.Slug
+	})
+}
+
+// subcategories returns the slugs of every subcategory.
+func subcategories() []string {
+	var slugs []string
+	for _, category := range taxonomy() {
+		for _, subcategory := range category.Subcategories {
+			slugs = append(slugs, subcategory.Slug)
+		}
+	}
+	return slugs
+}
+
+// validSubcategory reports whether subcategory belongs to category.
+func validSubcategory(category string, subcategory string) bool {
+	parent, ok := lo.Find(taxonomy(), func(item TaxonomyCategory) bool {
+		return item.Slug == category
+	})
+	return ok && lo.ContainsBy(parent.Subcategories, func(item TaxonomyCategory) bool {
+		return item.Slug == subcategory
+	})
+}
+
+// categoryLabel returns the display name of a category or subcategory slug
+// in language, falling back to the Indonesian label and then to the slug.
+func categoryLabel(language string, slug string) string {
+	for _, category := range taxonomy() {
+		for _, item := range append([]TaxonomyCategory{category}, category.Subcategories...) {
+			if item.Slug != slug {
+				continue
+			}
+			if label := item.Labels[editionLanguage(language)]; label != "" {
+				return label
+			}
+			return lo.CoalesceOrEmpty(item.Labels[LanguageIndonesian], slug)
+		}
+	}
+	return slug
+}
+
+// validateTaxonomy checks that every slug and alias is used once.
+func validateTaxonomy(categories []TaxonomyCategory) error {
+	seen := map[string]bool{}
+	use := func(slug string) error {
+		if slug == "" {
+			return fmt.Errorf("taxonomy has a category without slug")
+		}
+		if seen[slug] {
+			return fmt.Errorf("taxonomy uses %q more than once", slug)
+		}
+		seen[slug] = true
+		return nil
+	}
+	for _, category := range categories {
+		for _, item := range append([]TaxonomyCategory{category}, category.Subcategories...) {
+			if err := use(item.Slug); err != nil {
+				return err
+			}
+			for _, alias := range item.Aliases {
+				if err := use(alias); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// resolveCategory maps a stored category, possibly a former slug or a
+// subcategory, to the current category and subcategory.
+func resolveCategory(slug string) (category string, subcategory string, ok bool) {
+	for _, parent := range taxonomy() {
+		if parent.Slug == slug || lo.Contains(parent.Aliases, slug) {
+			return parent.Slug, "", true
+		}
+		for _, child := range parent.Subcategories {
+			if child.Slug == slug || lo.Contains(child.Aliases, slug) {
+				return parent.Slug, child.Slug, true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// Recategorization is the change of category of a stored article.
+type Recategorization struct {
+	ArticleID       int64
+	From            string
+	FromSubcategory string
+	To              string
+	Subcategory     string
+	// Unknown is set when the category could not be resolved and no
+	// fallback was given.
+	Unknown bool
+}
+
+// PlanRecategorization lists the stored articles whose category or
+// subcategory is not part of the current taxonomy and where they move to.
+// Articles with an unknown category move to fallback, or are reported as
+// unknown when fallback is empty.
+func PlanRecategorization(db *sql.DB, fallback string) ([]Recategorization, error) {
+	rows, err := db.Query(`SELECT id, category, subcategory FROM articles`)
+	if err != nil {
+		return nil, fmt.Errorf("error querying articles: %v", err)
+	}
+	defer rows.Close()
+
+	var plan []Recategorization
+	for rows.Next() {
+		var change Recategorization
+		if err := rows.Scan(&change.ArticleID, &change.From, &change.FromSubcategory); err != nil {
+			return nil, fmt.Errorf("error scanning article: %v", err)
+		}
+		if lo.Contains(categories(), change.From) && (change.FromSubcategory == "" || validSubcategory(change.From, change.FromSubcategory)) {
+			continue
+		}
+
+		category, subcategory, ok := resolveCategory(change.From)
+		switch {
+		case ok:
+			change.To = category
+			change.Subcategory = subcategory
+			// keep the subcategory, following its aliases, when it still
+			// belongs to the category
+			if parent, child, ok := resolveCategory(change.FromSubcategory); subcategory == "" && ok && parent == category {
+				change.Subcategory = child
+			}
+		case fallback != "":
+			change.To = fallback
+		default:
+			change.Unknown = true
+		}
+		if change.To == change.From && change.Subcategory == change.FromSubcategory {
+			continue
+		}
+		plan = append(plan, change)
+	}
+	return plan, rows.Err()
+}
+
+// ApplyRecategorization updates the stored articles in one transaction.
+func ApplyRecategorization(db *sql.DB, plan []Recategorization) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+	for _, change := range plan {
+		if change.Unknown {
+			continue
+		}
+		_, err := tx.Exec(`UPDATE articles SET category = ?, subcategory = ? WHERE id = ?`, change.To, change.Subcategory, change.ArticleID)
+		if err != nil {
+			return fmt.Errorf("error recategorizing article %d: %v", change.ArticleID, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing recategorization: %v", err)
+	}
+	return nil
+}
+
+// runRecategorize moves stored articles to the current taxonomy, following
+// the aliases of renamed categories.
+func runRecategorize(db *sql.DB, args []string) error {
+	flags := flag.NewFlagSet("recategorize", flag.ExitOnError)
+	fallback := flags.String("fallback", "", "category for articles whose category is unknown, by default they are left as is")
+	apply := flags.Bool("apply", false, "update the articles, otherwise only print the plan")
+	flags.Parse(args)
+
+	if *fallback != "" && !lo.Contains(categories(), *fallback) {
+		return fmt.Errorf("unknown fallback category %q", *fallback)
+	}
+	plan, err := PlanRecategorization(db, *fallback)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tFROM\tTO\tSUBCATEGORY")
+	unknown := 0
+	for _, change := range plan {
+		to := change.To
+		if change.Unknown {
+			to = "?"
+			unknown++
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", change.ArticleID, change.From, to, change.Subcategory)
+	}
+	w.Flush()
+	if unknown > 0 {
+		logger.Warn("Articles with unknown categories left as is, use -fallback to move them", "articles", unknown)
+	}
+
+	if !*apply {
+		return nil
+	}
+	if err := ApplyRecategorization(db, plan); err != nil {
+		return err
+	}
+	logger.Info("Articles recategorized", "articles", len(plan)-unknown)
+	return nil
+}
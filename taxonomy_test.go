@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRecategorization(t *testing.T) {
+	defer func(taxonomy []TaxonomyCategory) { config.Taxonomy = taxonomy }(config.Taxonomy)
+	config.Taxonomy = []TaxonomyCategory{
+		{Slug: "national", Subcategories: []TaxonomyCategory{
+			{Slug: "disasters", Aliases: []string{"bencana"}},
+			{Slug: "law", Aliases: []string{"crime"}},
+		}},
+		{Slug: "business", Aliases: []string{"economy"}, Subcategories: []TaxonomyCategory{
+			{Slug: "markets"},
+		}},
+		{Slug: "sports"},
+	}
+	if err := validateTaxonomy(config.Taxonomy); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		category    string
+		subcategory string
+		fallback    string
+		// to is the category and subcategory after applying the plan,
+		// empty when the article is not part of the plan
+		to      string
+		unknown bool
+	}{
+		{"current category", "sports", "", "", "", false},
+		{"current subcategory", "national", "law", "", "", false},
+		{"renamed category", "economy", "", "", "business/", false},
+		{"renamed category keeps its subcategory", "economy", "markets", "", "business/markets", false},
+		{"former category is now a subcategory", "crime", "", "", "national/law", false},
+		{"renamed subcategory", "national", "bencana", "", "national/disasters", false},
+		{"subcategory of another category is dropped", "business", "law", "", "business/", false},
+		{"unknown category", "gossip", "", "", "gossip/", true},
+		{"unknown category with fallback", "gossip", "", "national", "national/", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := testDB(t)
+			_, err := db.Exec(`INSERT INTO articles (id, title, excerpt, long_content, sources, links, category, subcategory, ai_model, created_at)
+				VALUES (1, 'title', 'excerpt', 'content', '', '', ?, ?, '', '')`, test.category, test.subcategory)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			plan, err := PlanRecategorization(db, test.fallback)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.to == "" {
+				if len(plan) != 0 {
+					t.Errorf("planned %+v", plan)
+				}
+				return
+			}
+			if len(plan) != 1 || plan[0].Unknown != test.unknown {
+				t.Fatalf("planned %+v", plan)
+			}
+
+			if err := ApplyRecategorization(db, plan); err != nil {
+				t.Fatal(err)
+			}
+			var category, subcategory string
+			if err := db.QueryRow(`SELECT category, subcategory FROM articles WHERE id = 1`).Scan(&category, &subcategory); err != nil {
+				t.Fatal(err)
+			}
+			if got := fmt.Sprintf("%s/%s", category, subcategory); got != test.to {
+				t.Errorf("moved to %s, want %s", got, test.to)
+			}
+		})
+	}
+}
+
+func TestValidateTaxonomy(t *testing.T) {
+	tests := []struct {
+		name     string
+		taxonomy []TaxonomyCategory
+		err      string
+	}{
+		{"default", defaultTaxonomy, ""},
+		{"missing slug", []TaxonomyCategory{{Slug: "national"}, {}}, "without slug"},
+		{"duplicate slug", []TaxonomyCategory{{Slug: "national"}, {Slug: "sports", Subcategories: []TaxonomyCategory{{Slug: "national"}}}}, `"national"`},
+		{"alias of another category", []TaxonomyCategory{{Slug: "national"}, {Slug: "business", Aliases: []string{"national"}}}, `"national"`},
+	}
+	for _, test := range tests {
+		err := validateTaxonomy(test.taxonomy)
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%s: %v", test.name, err)
+		case test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)):
+			t.Errorf("%s: error %v, want %q", test.name, err, test.err)
+		}
+	}
+}
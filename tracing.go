@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const serviceName = "ngopibentar-crawler"
+
+var tracer = otel.Tracer(serviceName)
+
+type runIDKey struct{}
+
+func withRunID(ctx context.Context, runID int64) context.Context {
+	return context.WithValue(ctx, runIDKey{}, runID)
+}
+
+func runIDFromContext(ctx context.Context) int64 {
+	runID, _ := ctx.Value(runIDKey{}).(int64)
+	return runID
+}
+
+// InitTracing installs an OTLP/HTTP trace exporter when
+// OTEL_EXPORTER_OTLP_ENDPOINT or OTEL_EXPORTER_OTLP_TRACES_ENDPOINT is set.
+// The exporter reads the remaining standard OTEL_* variables (headers,
+// protocol, timeout) itself. Without an endpoint spans are discarded.
+func InitTracing(ctx context.Context) (func(), error) {
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		return func() {}, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(serviceName)),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	logger.Info("Tracing enabled")
+
+	shutdown := func() {
+		if err := provider.Shutdown(context.Background()); err != nil {
+			logger.Error("Error shutting down tracer provider", "error", err)
+		}
+	}
+	return shutdown, nil
+}
+
+// startSpan starts a span tagged with the run ID carried by ctx.
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	if runID := runIDFromContext(ctx); runID != 0 {
+		attrs = append(attrs, attribute.Int64("run.id", runID))
+	}
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// endSpan records err on the span, if any, and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/genai"
+)
+
+// editionLanguageNames are the languages stories can be translated to,
+// besides the Indonesian original.
+var editionLanguageNames = map[string]string{
+	LanguageEnglish: "English",
+}
+
+// ArticleTranslation is a story rewritten in another edition language.
+type ArticleTranslation struct {
+	ArticleID   int64  `json:"article_id"`
+	Language    string `json:"language"`
+	Title       string `json:"title"`
+	Excerpt     string `json:"excerpt"`
+	LongContent string `json:"long_content"`
+	AiModel     string `json:"ai_model"`
+	// PromptVersion is the version of the translator prompt used.
+	PromptVersion string    `json:"prompt_version,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type translationItem struct {
+	Index       int    `json:"index"`
+	Title       string `json:"title"`
+	Excerpt     string `json:"excerpt"`
+	LongContent string `json:"long_content"`
+}
+
+type translationResponse struct {
+	Translations []translationItem `json:"translations"`
+}
+
+// editionLanguages reads the comma separated EDITION_LANGUAGES, skipping
+// unsupported ones. Empty means stories are only published in Indonesian.
+func editionLanguages() []string {
+	var languages []string
+	for _, language := range strings.Split(os.Getenv("EDITION_LANGUAGES"), ",") {
+		language = strings.ToLower(strings.TrimSpace(language))
+		if language == "" || language == LanguageIndonesian {
+			continue
+		}
+		if _, ok := editionLanguageNames[language]; !ok {
+			logger.Warn("Unsupported edition language, ignoring", "language", language)
+			continue
+		}
+		languages = append(languages, language)
+	}
+	return lo.Uniq(languages)
+}
+
+// validEdition reports whether language can be requested through the lang
+// parameter of the feeds and exports.
+func validEdition(language string) bool {
+	if language == "" || language == LanguageIndonesian {
+		return true
+	}
+	_, ok := editionLanguageNames[language]
+	return ok
+}
+
+// TranslateArticles asks Gemini for the language edition of the stories
+// in a second pass, so the summary itself is never held back by it.
+func TranslateArticles(ctx context.Context, articles []Article, language string) (_ []ArticleTranslation, err error) {
+	aiModel := "gemini-2.0-flash"
+	ctx, span := startSpan(ctx, "translator", attribute.Int("articles", len(articles)), attribute.String("language", language))
+	defer func() {
+		if err != nil {
+			err = &LLMError{Operation: "translate", Model: aiModel, Err: err}
+		}
+		endSpan(span, err)
+	}()
+
+	if len(articles) == 0 {
+		return nil, nil
+	}
+	name, ok := editionLanguageNames[language]
+	if !ok {
+		return nil, fmt.Errorf("unsupported edition language %q", language)
+	}
+
+	data := newPromptData()
+	data.Language = name
+	prompt, err := LoadPrompt(PromptTranslator, data)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := genai.NewClient(ctx, &genai.ClientConfig{
+		APIKey:  os.Getenv("GEMINI_API_KEY"),
+		Backend: genai.BackendGeminiAPI,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creating client: %v", err)
+	}
+
+	var items []translationItem
+	for i, article := range articles {
+		items = append(items, translationItem{Index: i, Title: article.Title, Excerpt: article.Excerpt, LongContent: article.LongContent})
+	}
+	jsonPayload, err := json.Marshal(items)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling payload: %v", err)
+	}
+
+	logger.Info("Translating articles", "language", language, "articles", len(articles))
+	parts := []*genai.Part{
+		{Text: string(jsonPayload)},
+	}
+	start := time.Now()
+	result, err := client.Models.GenerateContent(
+		ctx, aiModel,
+		[]*genai.Content{genai.NewContentFromParts(parts, genai.RoleUser)}, &genai.GenerateContentConfig{
+			SystemInstruction: &genai.Content{
+				Parts: []*genai.Part{
+					{Text: prompt.Text},
+				},
+			},
+			ResponseMIMEType: "application/json",
+			ResponseSchema: &genai.Schema{
+				Type: genai.TypeObject,
+				Properties: map[string]*genai.Schema{
+					"translations": {
+						Type: genai.TypeArray,
+						Items: &genai.Schema{
+							Type: genai.TypeObject,
+							Properties: map[string]*genai.Schema{
+								"index": {
+									Type: genai.TypeInteger,
+								},
+								"title": {
+									Type: genai.TypeString,
+								},
+								"excerpt": {
+									Type: genai.TypeString,
+								},
+								"long_content": {
+									Type: genai.TypeString,
+								},
+							},
+							Required: []string{"index", "title", "excerpt", "long_content"},
+						},
+					},
+				},
+				Required: []string{"translations"},
+			},
+		})
+	observeLLM(ctx, "translate", aiModel, start, result, err)
+	if err != nil {
+		return nil, fmt.Errorf("error generating content: %v", err)
+	}
+
+	if len(result.Candidates) == 0 {
+		return nil, fmt.Errorf("no candidates returned from Gemini API")
+	}
+
+	var response translationResponse
+	logger.Debug("translation result", "result", result.Candidates[0].Content.Parts[0].Text)
+	err = json.Unmarshal([]byte(result.Candidates[0].Content.Parts[0].Text), &response)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshaling result: %v", err)
+	}
+
+	createdAt := time.Now()
+	var translations []ArticleTranslation
+	for _, item := range response.Translations {
+		if item.Index < 0 || item.Index >= len(articles) {
+			logger.Warn("Translation for unknown article, ignoring", "index", item.Index)
+			continue
+		}
+		translations = append(translations, ArticleTranslation{
+			ArticleID:     articles[item.Index].ID,
+			Language:      language,
+			Title:         item.Title,
+			Excerpt:       item.Excerpt,
+			LongContent:   item.LongContent,
+			AiModel:       aiModel,
+			PromptVersion: prompt.Version,
+			CreatedAt:     createdAt,
+		})
+	}
+	return translations, nil
+}
+
+func InsertArticleTranslation(ctx context.Context, db *sql.DB, translation *ArticleTranslation) (err error) {
+	ctx, span := startSpan(ctx, "store.insert_article_translation", attribute.Int64("article.id", translation.ArticleID), attribute.String("language", translation.Language))
+	defer func() {
+		if err != nil {
+			err = &StoreError{Operation: fmt.Sprintf("inserting %s translation of article %d", translation.Language, translation.ArticleID), Err: err}
+		}
+		endSpan(span, err)
+	}()
+
+	_, err = db.ExecContext(ctx, `
+		INSERT OR REPLACE INTO article_translations (article_id, language, title, excerpt, long_content, ai_model, prompt_version, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, translation.ArticleID, translation.Language, translation.Title, translation.Excerpt, translation.LongContent, translation.AiModel,
+		translation.PromptVersion, translation.CreatedAt.Format(createdAtLayout))
+	return err
+}
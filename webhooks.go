@@ -0,0 +1,338 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/godruoyi/go-snowflake"
+	"github.com/samber/lo"
+)
+
+const (
+	EventArticleCreated = "article.created"
+	EventPing           = "ping"
+)
+
+const (
+	webhookMaxAttempts = 5
+	webhookBaseDelay   = 2 * time.Second
+	webhookTimeout     = 10 * time.Second
+)
+
+type WebhookSubscription struct {
+	ID        int64
+	URL       string
+	Secret    string
+	Events    []string
+	Active    bool
+	CreatedAt time.Time
+}
+
+type WebhookEvent struct {
+	ID        int64     `json:"id"`
+	Event     string    `json:"event"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// WebhookDispatcher delivers events to every matching subscription in the
+// background. Subscriptions are loaded on the first event, so a dispatcher
+// lives for one run. Call Wait before exiting so pending retries are not
+// lost; cancelling the dispatcher's context stops them.
+type WebhookDispatcher struct {
+	ctx         context.Context
+	db          *sql.DB
+	client      *http.Client
+	maxAttempts int
+	baseDelay   time.Duration
+	wg          sync.WaitGroup
+
+	load          sync.Once
+	subscriptions []WebhookSubscription
+}
+
+func NewWebhookDispatcher(ctx context.Context, db *sql.DB) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		ctx:         ctx,
+		db:          db,
+		client:      &http.Client{Timeout: webhookTimeout},
+		maxAttempts: webhookMaxAttempts,
+		baseDelay:   webhookBaseDelay,
+	}
+}
+
+// signWebhook returns the hex encoded HMAC-SHA256 of "<timestamp>.<body>".
+func signWebhook(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (d *WebhookDispatcher) Dispatch(event string, data any) {
+	d.load.Do(func() {
+		subscriptions, err := ListWebhookSubscriptions(d.db)
+		if err != nil {
+			logger.Error("Error listing webhook subscriptions", "error", err)
+			return
+		}
+		d.subscriptions = subscriptions
+	})
+	subscriptions := d.subscriptions
+
+	payload := WebhookEvent{
+		ID:        int64(snowflake.ID()),
+		Event:     event,
+		CreatedAt: time.Now(),
+		Data:      data,
+	}
+	for _, subscription := range subscriptions {
+		if !subscription.Active || !lo.Contains(subscription.Events, event) {
+			continue
+		}
+		d.wg.Add(1)
+		go func(subscription WebhookSubscription) {
+			defer d.wg.Done()
+			d.deliver(subscription, payload)
+		}(subscription)
+	}
+}
+
+func (d *WebhookDispatcher) Wait() {
+	d.wg.Wait()
+}
+
+// deliver posts the event to the subscription, retrying network errors,
+// 5xx and 429 responses with exponential backoff, and records every attempt
+// in webhook_deliveries. Other 4xx responses are not retried.
+func (d *WebhookDispatcher) deliver(subscription WebhookSubscription, payload WebhookEvent) bool {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		logger.Error("Error marshaling webhook payload", "event", payload.Event, "error", err)
+		return false
+	}
+
+	for attempt := 1; attempt <= d.maxAttempts; attempt++ {
+		statusCode, err := d.send(subscription, payload, body)
+		d.logDelivery(subscription, payload, attempt, statusCode, err)
+		if err == nil {
+			logger.Debug("Webhook delivered", "url", subscription.URL, "event", payload.Event, "attempt", attempt)
+			return true
+		}
+
+		logger.Error("Error delivering webhook", "url", subscription.URL, "event", payload.Event, "attempt", attempt, "error", err)
+		if !retryableWebhookStatus(statusCode) {
+			return false
+		}
+		if attempt == d.maxAttempts {
+			break
+		}
+		select {
+		case <-time.After(d.baseDelay * time.Duration(1<<(attempt-1))):
+		case <-d.ctx.Done():
+			return false
+		}
+	}
+	return false
+}
+
+// retryableWebhookStatus reports whether a failed delivery may succeed
+// later: network errors, which have no status, server errors and rate
+// limiting.
+func retryableWebhookStatus(statusCode int) bool {
+	return statusCode == 0 || statusCode == http.StatusTooManyRequests || statusCode >= 500
+}
+
+func (d *WebhookDispatcher) send(subscription WebhookSubscription, payload WebhookEvent, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodPost, subscription.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "ngopibentar-webhooks/1.0")
+	req.Header.Set("X-Webhook-Event", payload.Event)
+	req.Header.Set("X-Webhook-Delivery", strconv.FormatInt(payload.ID, 10))
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", "sha256="+signWebhook(subscription.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+func (d *WebhookDispatcher) logDelivery(subscription WebhookSubscription, payload WebhookEvent, attempt int, statusCode int, deliveryErr error) {
+	errorMessage := ""
+	if deliveryErr != nil {
+		errorMessage = deliveryErr.Error()
+	}
+	_, err := d.db.Exec(`
+		INSERT INTO webhook_deliveries (id, subscription_id, event_id, event, attempt, status_code, error, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, int64(snowflake.ID()), subscription.ID, payload.ID, payload.Event, attempt, statusCode, errorMessage, time.Now().Format(createdAtLayout))
+	if err != nil {
+		logger.Error("Error logging webhook delivery", "error", err)
+	}
+}
+
+func CreateWebhookSubscription(db *sql.DB, subscription *WebhookSubscription) error {
+	subscription.ID = int64(snowflake.ID())
+	subscription.CreatedAt = time.Now()
+	_, err := db.Exec(`
+		INSERT INTO webhook_subscriptions (id, url, secret, events, active, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, subscription.ID, subscription.URL, subscription.Secret, strings.Join(subscription.Events, ","), lo.Ternary(subscription.Active, 1, 0), subscription.CreatedAt.Format(createdAtLayout))
+	return err
+}
+
+func ListWebhookSubscriptions(db *sql.DB) ([]WebhookSubscription, error) {
+	rows, err := db.Query(`SELECT id, url, secret, events, active, created_at FROM webhook_subscriptions ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("error querying webhook subscriptions: %v", err)
+	}
+	defer rows.Close()
+
+	var subscriptions []WebhookSubscription
+	for rows.Next() {
+		var subscription WebhookSubscription
+		var events, createdAt string
+		if err := rows.Scan(&subscription.ID, &subscription.URL, &subscription.Secret, &events, &subscription.Active, &createdAt); err != nil {
+			return nil, fmt.Errorf("error scanning webhook subscription: %v", err)
+		}
+		subscription.Events = splitList(events)
+		subscription.CreatedAt, _ = time.ParseInLocation(createdAtLayout, createdAt, time.Local)
+		subscriptions = append(subscriptions, subscription)
+	}
+	return subscriptions, rows.Err()
+}
+
+func DeleteWebhookSubscription(db *sql.DB, id int64) error {
+	result, err := db.Exec(`DELETE FROM webhook_subscriptions WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("webhook subscription %d not found", id)
+	}
+	return nil
+}
+
+func runWebhooks(ctx context.Context, db *sql.DB, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: webhooks <add|list|remove|ping|deliveries> [flags]")
+	}
+
+	flags := flag.NewFlagSet("webhooks "+args[0], flag.ExitOnError)
+	switch args[0] {
+	case "add":
+		url := flags.String("url", "", "URL receiving the webhook")
+		secret := flags.String("secret", "", "secret used to sign the payload")
+		events := flags.String("events", EventArticleCreated, "comma separated list of events (article.created, extractor.degraded)")
+		flags.Parse(args[1:])
+		if *url == "" || *secret == "" {
+			return fmt.Errorf("url and secret are required")
+		}
+
+		subscription := WebhookSubscription{URL: *url, Secret: *secret, Events: splitList(*events), Active: true}
+		if err := CreateWebhookSubscription(db, &subscription); err != nil {
+			return fmt.Errorf("error creating webhook subscription: %v", err)
+		}
+		fmt.Printf("%d\n", subscription.ID)
+	case "list":
+		flags.Parse(args[1:])
+		subscriptions, err := ListWebhookSubscriptions(db)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tURL\tEVENTS\tACTIVE\tCREATED AT")
+		for _, subscription := range subscriptions {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%t\t%s\n", subscription.ID, subscription.URL, strings.Join(subscription.Events, ","), subscription.Active, subscription.CreatedAt.Format(createdAtLayout))
+		}
+		w.Flush()
+	case "remove":
+		id := flags.Int64("id", 0, "subscription ID")
+		flags.Parse(args[1:])
+		return DeleteWebhookSubscription(db, *id)
+	case "ping":
+		id := flags.Int64("id", 0, "subscription ID")
+		flags.Parse(args[1:])
+		subscriptions, err := ListWebhookSubscriptions(db)
+		if err != nil {
+			return err
+		}
+		subscription, ok := lo.Find(subscriptions, func(s WebhookSubscription) bool {
+			return s.ID == *id
+		})
+		if !ok {
+			return fmt.Errorf("webhook subscription %d not found", *id)
+		}
+
+		dispatcher := NewWebhookDispatcher(ctx, db)
+		payload := WebhookEvent{
+			ID:        int64(snowflake.ID()),
+			Event:     EventPing,
+			CreatedAt: time.Now(),
+			Data:      map[string]string{"message": "pong"},
+		}
+		if !dispatcher.deliver(subscription, payload) {
+			return fmt.Errorf("ping to %s failed", subscription.URL)
+		}
+	case "deliveries":
+		limit := flags.Int("limit", 20, "number of deliveries to show")
+		flags.Parse(args[1:])
+		rows, err := db.Query(`
+			SELECT d.event_id, d.event, s.url, d.attempt, d.status_code, d.error, d.created_at
+			FROM webhook_deliveries d
+			LEFT JOIN webhook_subscriptions s ON s.id = d.subscription_id
+			ORDER BY d.created_at DESC, d.id DESC
+			LIMIT ?
+		`, *limit)
+		if err != nil {
+			return fmt.Errorf("error querying webhook deliveries: %v", err)
+		}
+		defer rows.Close()
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "EVENT ID\tEVENT\tURL\tATTEMPT\tSTATUS\tERROR\tCREATED AT")
+		for rows.Next() {
+			var eventID int64
+			var event, createdAt, deliveryErr string
+			var url sql.NullString
+			var attempt, statusCode int
+			if err := rows.Scan(&eventID, &event, &url, &attempt, &statusCode, &deliveryErr, &createdAt); err != nil {
+				return fmt.Errorf("error scanning webhook delivery: %v", err)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%d\t%s\t%s\n", eventID, event, url.String, attempt, statusCode, deliveryErr, createdAt)
+		}
+		w.Flush()
+		return rows.Err()
+	default:
+		return fmt.Errorf("unknown webhooks command %q", args[0])
+	}
+	return nil
+}
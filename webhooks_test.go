@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	_ "github.com/tursodatabase/go-libsql"
+)
+
+// testDB opens a migrated database in a temporary directory.
+func testDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("libsql", "file:"+filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	initMigration(db)
+	return db
+}
+
+// webhookReceiver records the deliveries it accepts and answers with the
+// given statuses in turn, then 200.
+type webhookReceiver struct {
+	t        *testing.T
+	secret   string
+	mu       sync.Mutex
+	statuses []int
+	requests int
+	events   []WebhookEvent
+}
+
+func (r *webhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	signature, _ := strings.CutPrefix(req.Header.Get("X-Webhook-Signature"), "sha256=")
+	expected := signWebhook(r.secret, req.Header.Get("X-Webhook-Timestamp"), body)
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		r.t.Errorf("invalid signature %q", signature)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests++
+	if len(r.statuses) > 0 {
+		status := r.statuses[0]
+		r.statuses = r.statuses[1:]
+		w.WriteHeader(status)
+		return
+	}
+	var event WebhookEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		r.t.Errorf("invalid payload: %v", err)
+	}
+	if got := req.Header.Get("X-Webhook-Event"); got != event.Event {
+		r.t.Errorf("X-Webhook-Event %q for a %q payload", got, event.Event)
+	}
+	r.events = append(r.events, event)
+}
+
+func TestWebhookDispatcher(t *testing.T) {
+	tests := []struct {
+		name      string
+		statuses  []int
+		requests  int
+		delivered int
+	}{
+		{"delivered", nil, 1, 1},
+		{"retried on server errors", []int{http.StatusInternalServerError, http.StatusBadGateway}, 3, 1},
+		{"retried when rate limited", []int{http.StatusTooManyRequests}, 2, 1},
+		{"permanent client error", []int{http.StatusGone}, 1, 0},
+		{"gives up", []int{500, 500, 500, 500, 500}, webhookMaxAttempts, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := testDB(t)
+			receiver := &webhookReceiver{t: t, secret: "s3cret", statuses: test.statuses}
+			server := httptest.NewServer(receiver)
+			defer server.Close()
+			subscription := WebhookSubscription{URL: server.URL, Secret: receiver.secret, Events: []string{EventArticleCreated}, Active: true}
+			if err := CreateWebhookSubscription(db, &subscription); err != nil {
+				t.Fatal(err)
+			}
+
+			dispatcher := NewWebhookDispatcher(t.Context(), db)
+			dispatcher.baseDelay = 0
+			dispatcher.Dispatch(EventArticleCreated, Article{ID: 1, Title: "Banjir"})
+			dispatcher.Dispatch(EventPing, nil)
+			dispatcher.Wait()
+
+			if receiver.requests != test.requests {
+				t.Errorf("%d requests, want %d", receiver.requests, test.requests)
+			}
+			if len(receiver.events) != test.delivered {
+				t.Fatalf("%d events delivered, want %d", len(receiver.events), test.delivered)
+			}
+			if test.delivered > 0 && receiver.events[0].Event != EventArticleCreated {
+				t.Errorf("delivered %q", receiver.events[0].Event)
+			}
+			var attempts int
+			db.QueryRow(`SELECT COUNT(*) FROM webhook_deliveries WHERE subscription_id = ?`, subscription.ID).Scan(&attempts)
+			if attempts != test.requests {
+				t.Errorf("%d attempts logged, want %d", attempts, test.requests)
+			}
+		})
+	}
+}
+
+func TestWebhookDispatcherLoadsSubscriptionsOnce(t *testing.T) {
+	db := testDB(t)
+	receiver := &webhookReceiver{t: t, secret: "s3cret"}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	dispatcher := NewWebhookDispatcher(t.Context(), db)
+	dispatcher.Dispatch(EventArticleCreated, Article{ID: 1})
+	// subscriptions added during a run are used from the next one
+	subscription := WebhookSubscription{URL: server.URL, Secret: receiver.secret, Events: []string{EventArticleCreated}, Active: true}
+	if err := CreateWebhookSubscription(db, &subscription); err != nil {
+		t.Fatal(err)
+	}
+	dispatcher.Dispatch(EventArticleCreated, Article{ID: 2})
+	dispatcher.Wait()
+	if receiver.requests != 0 {
+		t.Errorf("%d requests within the run", receiver.requests)
+	}
+
+	dispatcher = NewWebhookDispatcher(t.Context(), db)
+	dispatcher.Dispatch(EventArticleCreated, Article{ID: 3})
+	dispatcher.Wait()
+	if receiver.requests != 1 {
+		t.Errorf("%d requests in the next run, want 1", receiver.requests)
+	}
+}
+
+func TestWebhookDispatcherCancelled(t *testing.T) {
+	db := testDB(t)
+	receiver := &webhookReceiver{t: t, secret: "s3cret", statuses: []int{500, 500, 500, 500, 500}}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+	subscription := WebhookSubscription{URL: server.URL, Secret: receiver.secret, Events: []string{EventArticleCreated}, Active: true}
+	if err := CreateWebhookSubscription(db, &subscription); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	dispatcher := NewWebhookDispatcher(ctx, db)
+	dispatcher.baseDelay = time.Hour
+	dispatcher.Dispatch(EventArticleCreated, Article{ID: 1})
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	done := make(chan struct{})
+	go func() {
+		dispatcher.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait blocked through the backoff after cancellation")
+	}
+	if receiver.requests != 1 {
+		t.Errorf("%d requests, want 1", receiver.requests)
+	}
+}